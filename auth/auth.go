@@ -0,0 +1,229 @@
+// Package auth implements Netlify's OAuth2 authorization code flow, to obtain
+// access tokens for a Provider on behalf of users instead of asking them for
+// a personal access token.
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	netlify "github.com/CL0Pinette/libdns-netlify"
+)
+
+const (
+	// DefaultAuthURL is the URL users are sent to to authorize an
+	// application
+	DefaultAuthURL = "https://app.netlify.com/authorize"
+	// DefaultTokenURL is the URL authorization codes and refresh tokens are
+	// exchanged for access tokens at
+	DefaultTokenURL = "https://api.netlify.com/oauth/token"
+)
+
+// Config describes an OAuth application registered on Netlify.
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where Netlify sends users back to with the
+	// authorization code. It must match the one of the application
+	RedirectURL string
+
+	// AuthURL and TokenURL default to DefaultAuthURL and
+	// DefaultTokenURL
+	AuthURL  string
+	TokenURL string
+
+	// HTTPClient sends the token requests. Defaults to
+	// http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// Token is an access token obtained from Netlify.
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	// Expiry is when the access token expires. It is zero if the token
+	// doesn't expire
+	Expiry time.Time `json:"expiry,omitempty"`
+}
+
+// expiryDelta is how long before its expiry a token is refreshed, so that it
+// doesn't expire while a request is in flight
+const expiryDelta = 10 * time.Second
+
+// Valid reports whether t has an access token which hasn't expired.
+func (t *Token) Valid() bool {
+	if t == nil || t.AccessToken == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || time.Now().Add(expiryDelta).Before(t.Expiry)
+}
+
+// TokenError is returned when Netlify rejects a token request.
+type TokenError struct {
+	StatusCode  int
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *TokenError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("token request failed: HTTP %d", e.StatusCode)
+	}
+	if e.Description == "" {
+		return fmt.Sprintf("token request failed: HTTP %d: %s", e.StatusCode, e.Code)
+	}
+	return fmt.Sprintf("token request failed: HTTP %d: %s: %s", e.StatusCode, e.Code, e.Description)
+}
+
+// AuthCodeURL returns the URL to send users to to authorize the application.
+// state is sent back with the authorization code, to check that the callback
+// matches a request made by the application.
+func (c *Config) AuthCodeURL(state string) string {
+	authURL := c.AuthURL
+	if authURL == "" {
+		authURL = DefaultAuthURL
+	}
+
+	qs := make(url.Values)
+	qs.Set("client_id", c.ClientID)
+	qs.Set("response_type", "code")
+	qs.Set("redirect_uri", c.RedirectURL)
+	if state != "" {
+		qs.Set("state", state)
+	}
+
+	sep := "?"
+	if strings.Contains(authURL, "?") {
+		sep = "&"
+	}
+	return authURL + sep + qs.Encode()
+}
+
+// Exchange exchanges the authorization code received on the redirect URL for
+// a token.
+func (c *Config) Exchange(ctx context.Context, code string) (*Token, error) {
+	form := make(url.Values)
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.RedirectURL)
+	return c.requestToken(ctx, form)
+}
+
+// Refresh exchanges a refresh token for a new token. The refresh token is
+// kept in the new token if Netlify doesn't send another one.
+func (c *Config) Refresh(ctx context.Context, refreshToken string) (*Token, error) {
+	form := make(url.Values)
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", refreshToken)
+	tok, err := c.requestToken(ctx, form)
+	if err != nil {
+		return nil, err
+	}
+	if tok.RefreshToken == "" {
+		tok.RefreshToken = refreshToken
+	}
+	return tok, nil
+}
+
+// requestToken sends a token request with the given form. It returns the
+// token, or a *TokenError if Netlify rejected the request
+func (c *Config) requestToken(ctx context.Context, form url.Values) (*Token, error) {
+	tokenURL := c.TokenURL
+	if tokenURL == "" {
+		tokenURL = DefaultTokenURL
+	}
+	form.Set("client_id", c.ClientID)
+	form.Set("client_secret", c.ClientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		tokenErr := &TokenError{StatusCode: resp.StatusCode}
+		json.Unmarshal(body, tokenErr)
+		return nil, tokenErr
+	}
+
+	var result struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.AccessToken == "" {
+		return nil, errors.New("token response has no access token")
+	}
+
+	tok := &Token{
+		AccessToken:  result.AccessToken,
+		TokenType:    result.TokenType,
+		RefreshToken: result.RefreshToken,
+	}
+	if result.ExpiresIn > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(result.ExpiresIn) * time.Second)
+	}
+	return tok, nil
+}
+
+// TokenSource returns a netlify.TokenSource supplying the access token of
+// tok, refreshing it with its refresh token once it has expired. Use it as
+// the TokenSource of the provider.
+func (c *Config) TokenSource(tok *Token) netlify.TokenSource {
+	return &tokenSource{config: c, token: tok}
+}
+
+// tokenSource supplies a token, refreshing it when needed
+type tokenSource struct {
+	config *Config
+	mu     sync.Mutex
+	token  *Token
+}
+
+func (s *tokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.Valid() {
+		return s.token.AccessToken, nil
+	}
+	if s.token == nil || s.token.RefreshToken == "" {
+		return "", errors.New("token expired and can't be refreshed")
+	}
+	tok, err := s.config.Refresh(ctx, s.token.RefreshToken)
+	if err != nil {
+		return "", err
+	}
+	s.token = tok
+	return tok.AccessToken, nil
+}
@@ -0,0 +1,63 @@
+package netlify
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// circuitBreaker stops sending requests after too many consecutive failures,
+// until a cool-down period has elapsed
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// allowRequest returns an error wrapping ErrCircuitOpen if requests must not be
+// sent
+func (p *Provider) allowRequest() error {
+	if p.CircuitBreakerThreshold <= 0 {
+		return nil
+	}
+
+	b := &p.breaker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if now := p.clock().Now(); now.Before(b.openUntil) {
+		return fmt.Errorf("retry in %s: %w", b.openUntil.Sub(now).Round(time.Second), ErrCircuitOpen)
+	}
+	return nil
+}
+
+// recordResult counts the consecutive failed requests, opening the circuit
+// once there are CircuitBreakerThreshold of them
+func (p *Provider) recordResult(err error) {
+	if p.CircuitBreakerThreshold <= 0 {
+		return
+	}
+
+	b := &p.breaker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil || !retryable(err) {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= p.CircuitBreakerThreshold {
+		cooldown := p.CircuitBreakerCooldown
+		if cooldown <= 0 {
+			cooldown = defaultCircuitBreakerCooldown
+		}
+		b.openUntil = p.clock().Now().Add(cooldown)
+		b.failures = 0
+	}
+}
+
+// defaultCircuitBreakerCooldown is how long the circuit stays open when
+// CircuitBreakerCooldown isn't set
+const defaultCircuitBreakerCooldown = 30 * time.Second
@@ -0,0 +1,229 @@
+package netlify
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheStats counts the lookups served by the caches of the provider (hits)
+// and those which needed a request to Netlify's API (misses).
+type CacheStats struct {
+	ZoneHits     int64
+	ZoneMisses   int64
+	RecordHits   int64
+	RecordMisses int64
+}
+
+type cacheCounters struct {
+	zoneHits     counter
+	zoneMisses   counter
+	recordHits   counter
+	recordMisses counter
+}
+
+// counter is a count safe for concurrent use
+type counter struct {
+	mu sync.Mutex
+	n  int64
+}
+
+func (c *counter) Add(delta int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.n += delta
+}
+
+func (c *counter) Load() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n
+}
+
+// CacheStats returns the hit and miss counts of the zone and records caches.
+// Record lookups are only counted when the records cache is enabled.
+func (p *Provider) CacheStats() CacheStats {
+	return CacheStats{
+		ZoneHits:     p.cacheCounters.zoneHits.Load(),
+		ZoneMisses:   p.cacheCounters.zoneMisses.Load(),
+		RecordHits:   p.cacheCounters.recordHits.Load(),
+		RecordMisses: p.cacheCounters.recordMisses.Load(),
+	}
+}
+
+// cacheKey is the key of the zone and records caches, which depend on the
+// credentials as the responses they hold do. name is the zone name in the
+// zone cache, and the zone ID in the records cache
+type cacheKey struct {
+	token tokenHash
+	name  string
+}
+
+// cacheKey returns the key of name for the access token the calls made with
+// ctx authenticate with
+func (p *Provider) cacheKey(ctx context.Context, name string) (cacheKey, error) {
+	token, err := p.token(ctx)
+	if err != nil {
+		return cacheKey{}, err
+	}
+	return cacheKey{token: hashToken(token), name: name}, nil
+}
+
+type cachedRecords struct {
+	records []netlifyDNSRecord
+	expires time.Time
+}
+
+// getCachedRecords returns the cached records of the zone, if the records
+// cache is enabled and they haven't expired
+func (p *Provider) getCachedRecords(key cacheKey) ([]netlifyDNSRecord, bool) {
+	if p.RecordsCacheTTL <= 0 {
+		return nil, false
+	}
+
+	p.recordsMu.Lock()
+	defer p.recordsMu.Unlock()
+
+	cached, ok := p.records[key]
+	if !ok || !p.clock().Now().Before(cached.expires) {
+		p.cacheCounters.recordMisses.Add(1)
+		return nil, false
+	}
+	p.cacheCounters.recordHits.Add(1)
+	return cached.records, true
+}
+
+// cacheRecords caches the records of the zone, if the records cache is
+// enabled
+func (p *Provider) cacheRecords(key cacheKey, records []netlifyDNSRecord) {
+	if p.RecordsCacheTTL <= 0 {
+		return
+	}
+
+	p.recordsMu.Lock()
+	defer p.recordsMu.Unlock()
+
+	if p.records == nil {
+		p.records = make(map[cacheKey]cachedRecords)
+	}
+	p.records[key] = cachedRecords{
+		records: records,
+		expires: p.clock().Now().Add(p.RecordsCacheTTL),
+	}
+}
+
+// invalidateRecords drops the cached records of the zone, for every access
+// token
+func (p *Provider) invalidateRecords(zoneID string) {
+	p.recordsMu.Lock()
+	defer p.recordsMu.Unlock()
+
+	for key := range p.records {
+		if key.name == zoneID {
+			delete(p.records, key)
+		}
+	}
+}
+
+// maxConditionalEntries bounds the number of responses kept in the
+// conditional cache; the least recently used one is evicted beyond that
+const maxConditionalEntries = 256
+
+// conditionalEntry is a response body cached with its validators, to be
+// revalidated with a conditional request
+type conditionalEntry struct {
+	etag         string
+	lastModified string
+	body         []byte
+	used         uint64
+}
+
+// conditionalKey is the key of the conditional cache for a request, which
+// depends on the credentials as the response does
+type conditionalKey struct {
+	token tokenHash
+	url   string
+}
+
+// conditionalRequest returns the key of the conditional cache for req, and
+// false if its response isn't cached. Only the lists of zones and records,
+// which are fetched repeatedly, are cached
+func (p *Provider) conditionalRequest(req *http.Request) (conditionalKey, bool) {
+	if !p.ConditionalRequests || req.Method != http.MethodGet {
+		return conditionalKey{}, false
+	}
+	if !strings.HasSuffix(req.URL.Path, "/dns_zones") && !strings.HasSuffix(req.URL.Path, "/dns_records") {
+		return conditionalKey{}, false
+	}
+	return conditionalKey{token: requestToken(req), url: req.URL.String()}, true
+}
+
+// setConditionalHeaders makes req a conditional request if a response to
+// it is cached
+func (p *Provider) setConditionalHeaders(req *http.Request) {
+	key, ok := p.conditionalRequest(req)
+	if !ok {
+		return
+	}
+
+	p.conditionalMu.Lock()
+	entry, ok := p.conditional[key]
+	p.conditionalMu.Unlock()
+	if !ok {
+		return
+	}
+
+	if entry.etag != "" {
+		req.Header.Set("If-None-Match", entry.etag)
+	}
+	if entry.lastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.lastModified)
+	}
+}
+
+// conditionalBody returns the body of the response to req: the cached body
+// if the response is a 304 Not Modified, the given body otherwise, which is
+// then cached if the response has validators
+func (p *Provider) conditionalBody(req *http.Request, resp *http.Response, body []byte) []byte {
+	key, ok := p.conditionalRequest(req)
+	if !ok {
+		return body
+	}
+
+	p.conditionalMu.Lock()
+	defer p.conditionalMu.Unlock()
+	p.conditionalUses++
+
+	if resp.StatusCode == http.StatusNotModified {
+		if entry, ok := p.conditional[key]; ok {
+			entry.used = p.conditionalUses
+			p.conditional[key] = entry
+			return entry.body
+		}
+		return body
+	}
+
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		delete(p.conditional, key)
+		return body
+	}
+	if p.conditional == nil {
+		p.conditional = make(map[conditionalKey]conditionalEntry)
+	}
+	if _, ok := p.conditional[key]; !ok && len(p.conditional) >= maxConditionalEntries {
+		var oldest conditionalKey
+		var oldestUse uint64
+		for k, entry := range p.conditional {
+			if oldestUse == 0 || entry.used < oldestUse {
+				oldest, oldestUse = k, entry.used
+			}
+		}
+		delete(p.conditional, oldest)
+	}
+	p.conditional[key] = conditionalEntry{etag: etag, lastModified: lastModified, body: body, used: p.conditionalUses}
+	return body
+}
@@ -0,0 +1,203 @@
+package netlify
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/netlify/open-api/v2/go/models"
+)
+
+func TestRecordsCache(t *testing.T) {
+	api, p := newFakeAPI(t)
+	clock := newFakeClock()
+	p.Clock = clock
+	p.RecordsCacheTTL = time.Minute
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: "192.0.2.1"})
+
+	ctx := context.Background()
+	getRecords := func(want int) {
+		t.Helper()
+		records, err := p.GetRecords(ctx, "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != want {
+			t.Errorf("got %d records, want %d", len(records), want)
+		}
+	}
+	lists := func() int {
+		return api.countRequests(http.MethodGet, "/dns_records")
+	}
+
+	getRecords(1)
+	getRecords(1)
+	if n := lists(); n != 1 {
+		t.Errorf("listed the records %d times, want the second listing served from the cache", n)
+	}
+
+	// a create invalidates the cached records of the zone
+	if _, err := p.AppendRecords(ctx, "example.com", []libdns.Record{{Type: "A", Name: "api", Value: "192.0.2.2"}}); err != nil {
+		t.Fatal(err)
+	}
+	getRecords(2)
+	if n := lists(); n != 2 {
+		t.Errorf("listed the records %d times, want the listing after the create sent", n)
+	}
+
+	// the cached records expire after RecordsCacheTTL
+	clock.advance(time.Minute)
+	getRecords(2)
+	if n := lists(); n != 3 {
+		t.Errorf("listed the records %d times, want the expired records listed again", n)
+	}
+}
+
+func TestCachesScopedByToken(t *testing.T) {
+	api, p := newFakeAPI(t)
+	p.Clock = newFakeClock()
+	p.RecordsCacheTTL = time.Minute
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: "192.0.2.1"})
+
+	// another account has its own example.com zone
+	const otherToken = "nfp_othertoken"
+	api.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer "+otherToken {
+			return false
+		}
+		switch r.URL.Path {
+		case "/dns_zones":
+			writeJSON(w, http.StatusOK, []netlifyZone{{DNSZone: &models.DNSZone{ID: "zone2", Name: "example.com"}}})
+		case "/dns_zones/zone2/dns_records":
+			writeJSON(w, http.StatusOK, []netlifyDNSRecord{{DNSRecord: &models.DNSRecord{
+				ID: "rec9", DNSZoneID: "zone2", Type: "A", Hostname: "www.example.com", Value: "192.0.2.9",
+			}}})
+		default:
+			http.NotFound(w, r)
+		}
+		return true
+	}
+
+	tests := []struct {
+		ctx  context.Context
+		want string
+	}{
+		{context.Background(), "192.0.2.1"},
+		{WithToken(context.Background(), otherToken), "192.0.2.9"},
+	}
+	for i := 0; i < 2; i++ {
+		for _, tt := range tests {
+			records, err := p.GetRecords(tt.ctx, "example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != 1 || records[0].Value != tt.want {
+				t.Errorf("got %+v, want the record with %s", records, tt.want)
+			}
+		}
+	}
+
+	if n := api.countRequests(http.MethodGet, "/dns_zones"); n != 2 {
+		t.Errorf("looked the zone up %d times, want once per token", n)
+	}
+	if n := api.countRequests(http.MethodGet, "/dns_records"); n != 2 {
+		t.Errorf("listed the records %d times, want once per token", n)
+	}
+}
+
+func TestRecordsCacheDisabled(t *testing.T) {
+	api, p := newFakeAPI(t)
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := p.GetRecords(ctx, "example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := api.countRequests(http.MethodGet, "/dns_records"); n != 2 {
+		t.Errorf("listed the records %d times, want 2", n)
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	_, p := newFakeAPI(t)
+	p.Clock = newFakeClock()
+	p.RecordsCacheTTL = time.Minute
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := p.GetRecords(ctx, "example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := CacheStats{ZoneHits: 1, ZoneMisses: 1, RecordHits: 1, RecordMisses: 1}
+	if got := p.CacheStats(); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestCacheStatsRecordsCacheDisabled(t *testing.T) {
+	_, p := newFakeAPI(t)
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := p.GetRecords(ctx, "example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := CacheStats{ZoneHits: 1, ZoneMisses: 1}
+	if got := p.CacheStats(); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestConditionalCacheScope(t *testing.T) {
+	p := &Provider{ConditionalRequests: true}
+	request := func(method, path, token string) *http.Request {
+		req, _ := http.NewRequest(method, "https://api.netlify.com/api/v1"+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req
+	}
+
+	for _, req := range []*http.Request{
+		request(http.MethodGet, "/dns_zones/zone1/dns_records/rec1", testToken),
+		request(http.MethodPost, "/dns_zones/zone1/dns_records", testToken),
+	} {
+		if _, ok := p.conditionalRequest(req); ok {
+			t.Errorf("%s %s is cached, want only lists cached", req.Method, req.URL.Path)
+		}
+	}
+
+	a, ok := p.conditionalRequest(request(http.MethodGet, "/dns_zones/zone1/dns_records", testToken))
+	if !ok {
+		t.Fatal("the list of records isn't cached")
+	}
+	b, _ := p.conditionalRequest(request(http.MethodGet, "/dns_zones/zone1/dns_records", "nfp_othertoken"))
+	if a == b {
+		t.Error("got the same key for two tokens, want responses cached per token")
+	}
+}
+
+func TestConditionalCacheBounded(t *testing.T) {
+	p := &Provider{ConditionalRequests: true}
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Etag": {`"v1"`}}}
+
+	for i := 0; i <= maxConditionalEntries; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "https://api.netlify.com/api/v1/dns_zones?name=zone"+strconv.Itoa(i), nil)
+		p.conditionalBody(req, resp, []byte("[]"))
+	}
+	if n := len(p.conditional); n != maxConditionalEntries {
+		t.Errorf("cached %d responses, want %d", n, maxConditionalEntries)
+	}
+	if _, ok := p.conditional[conditionalKey{
+		token: requestToken(&http.Request{Header: http.Header{}}),
+		url:   "https://api.netlify.com/api/v1/dns_zones?name=zone0",
+	}]; ok {
+		t.Error("kept the least recently used response, want it evicted")
+	}
+}
@@ -0,0 +1,182 @@
+// Package cassette records the HTTP interactions of a Provider with Netlify's
+// API to a file and replays them later, so that tests can run without network
+// access or a real access token.
+package cassette
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Mode selects whether a Recorder records or replays interactions.
+type Mode int
+
+const (
+	// ModeRecord sends the requests to the real API and records them.
+	ModeRecord Mode = iota
+	// ModeReplay answers the requests from the recorded interactions.
+	ModeReplay
+)
+
+// Interaction is a recorded request and its response. Request headers are
+// not recorded, so the access token never ends up in a cassette.
+type Interaction struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	RequestBody  string      `json:"request_body,omitempty"`
+	StatusCode   int         `json:"status_code"`
+	Header       http.Header `json:"header,omitempty"`
+	ResponseBody string      `json:"response_body,omitempty"`
+}
+
+// Recorder is an http.RoundTripper recording or replaying interactions. Use
+// it as the Transport of the HTTPClient of the provider.
+type Recorder struct {
+	// Transport sends the requests when recording. Defaults to
+	// http.DefaultTransport
+	Transport http.RoundTripper
+
+	path         string
+	mode         Mode
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// New returns a Recorder backed by the cassette file at path. In replay mode
+// the cassette is loaded from the file.
+func New(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{path: path, mode: mode}
+	if mode == ModeReplay {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(data, &r.interactions)
+		if err != nil {
+			return nil, err
+		}
+		r.used = make([]bool, len(r.interactions))
+	}
+	return r, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	if r.mode == ModeReplay {
+		return r.replay(req, string(reqBody))
+	}
+	return r.record(req, string(reqBody))
+}
+
+// replay answers req with the first unused interaction matching its method,
+// URL and body
+func (r *Recorder) replay(req *http.Request, reqBody string) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, in := range r.interactions {
+		if r.used[i] || in.Method != req.Method || in.URL != req.URL.String() || in.RequestBody != reqBody {
+			continue
+		}
+		r.used[i] = true
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", in.StatusCode, http.StatusText(in.StatusCode)),
+			StatusCode: in.StatusCode,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     in.Header.Clone(),
+			Body:       ioutil.NopCloser(bytes.NewReader([]byte(in.ResponseBody))),
+			Request:    req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("cassette: no recorded interaction for %s %s", req.Method, req.URL)
+}
+
+// record sends req with the underlying transport and records the interaction
+func (r *Recorder) record(req *http.Request, reqBody string) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	// bodies are recorded as strings, which can't hold compressed bytes,
+	// so compressed responses are recorded and returned decompressed
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		if respBody, err = gunzip(respBody); err != nil {
+			return nil, err
+		}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = int64(len(respBody))
+		resp.Uncompressed = true
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, Interaction{
+		Method:       req.Method,
+		URL:          req.URL.String(),
+		RequestBody:  reqBody,
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		ResponseBody: string(respBody),
+	})
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// gunzip returns the decompressed content of the gzip data
+func gunzip(data []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	return ioutil.ReadAll(gz)
+}
+
+// Save writes the recorded interactions to the cassette file. It does nothing
+// in replay mode.
+func (r *Recorder) Save() error {
+	if r.mode == ModeReplay {
+		return nil
+	}
+
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.interactions, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.path, data, 0o600)
+}
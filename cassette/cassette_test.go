@@ -0,0 +1,146 @@
+package cassette_test
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	netlify "github.com/CL0Pinette/libdns-netlify"
+	"github.com/CL0Pinette/libdns-netlify/cassette"
+	"github.com/libdns/libdns"
+)
+
+const token = "nfp_cassettetoken1234567890"
+
+// newServer returns a server answering the zone lookup, creation and fetch of
+// a record of the zone example.com, compressing its responses
+func newServer(t *testing.T) *httptest.Server {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body interface{}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/dns_zones":
+			body = []map[string]string{{"id": "zone1", "name": "example.com"}}
+		case r.Method == http.MethodPost && r.URL.Path == "/dns_zones/zone1/dns_records":
+			var rec map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&rec)
+			rec["id"] = "rec1"
+			body = rec
+		case r.Method == http.MethodGet && r.URL.Path == "/dns_zones/zone1/dns_records/rec1":
+			body = map[string]interface{}{"id": "rec1", "type": "A", "hostname": "www.example.com", "value": "192.0.2.1", "ttl": 3600}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			json.NewEncoder(w).Encode(body)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		json.NewEncoder(gz).Encode(body)
+		gz.Close()
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// createAndGet creates a record with p and gets it back by ID
+func createAndGet(t *testing.T, p *netlify.Provider) libdns.Record {
+	t.Helper()
+
+	ctx := context.Background()
+	created, err := p.AppendRecords(ctx, "example.com", []libdns.Record{
+		{Type: "A", Name: "www", Value: "192.0.2.1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 1 || created[0].ID != "rec1" {
+		t.Fatalf("created %+v, want a record with ID rec1", created)
+	}
+	rec, err := p.GetRecordByID(ctx, "example.com", created[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rec
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	server := newServer(t)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	recorder, err := cassette.New(path, cassette.ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &netlify.Provider{
+		PersonnalAccessToken: token,
+		BaseURL:              server.URL,
+		HTTPClient:           &http.Client{Transport: recorder},
+	}
+	recorded := createAndGet(t, p)
+	if err := recorder.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), token) {
+		t.Errorf("cassette contains the access token:\n%s", data)
+	}
+	if !strings.Contains(string(data), `\"hostname\":\"www.example.com\"`) {
+		t.Errorf("cassette lacks the decompressed responses:\n%s", data)
+	}
+
+	// the replay gets no answer from the server, which is closed
+	server.Close()
+	player, err := cassette.New(path, cassette.ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = &netlify.Provider{
+		PersonnalAccessToken: "nfp_othertoken",
+		BaseURL:              server.URL,
+		HTTPClient:           &http.Client{Transport: player},
+	}
+	replayed := createAndGet(t, p)
+	if replayed != recorded {
+		t.Errorf("replayed %+v, want %+v", replayed, recorded)
+	}
+}
+
+func TestReplayUnknownRequest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	if err := ioutil.WriteFile(path, []byte("[]"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	player, err := cassette.New(path, cassette.ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &netlify.Provider{
+		PersonnalAccessToken: token,
+		BaseURL:              "https://api.netlify.example/api/v1",
+		HTTPClient:           &http.Client{Transport: player},
+	}
+
+	_, err = p.GetRecords(context.Background(), "example.com")
+	if err == nil || !strings.Contains(err.Error(), "no recorded interaction") {
+		t.Errorf("got error %v, want a missing interaction", err)
+	}
+}
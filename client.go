@@ -2,43 +2,138 @@ package netlify
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/libdns/libdns"
+	"github.com/netlify/open-api/v2/go/models"
 )
 
 // createRecord creates a DNS record in the specified zone. It returns the DNS
 // record created
 func (p *Provider) createRecord(ctx context.Context, zoneInfo netlifyZone, record libdns.Record) (netlifyDNSRecord, error) {
-	jsonBytes, err := json.Marshal(netlifyRecord(record))
+	ttl, err := p.applyTTL(record, true)
 	if err != nil {
 		return netlifyDNSRecord{}, err
 	}
-	reqURL := fmt.Sprintf("%s/dns_zones/%s/dns_records", baseURL, zoneInfo.ID)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, bytes.NewReader(jsonBytes))
+	record.TTL = ttl
+
+	if err := p.validateRecord(record, zoneInfo.Name); err != nil {
+		return netlifyDNSRecord{}, err
+	}
+
+	if IsManagedRecord(record) && !p.AllowManagedRecordChanges {
+		return netlifyDNSRecord{}, fmt.Errorf("%s record %s: %w", record.Type, record.Name, ErrManagedRecord)
+	}
+
+	if err := p.checkCNAMEConflict(ctx, zoneInfo, record); err != nil {
+		return netlifyDNSRecord{}, err
+	}
+
+	if err := p.beforeMutate(ctx, OpCreate, record); err != nil {
+		return netlifyDNSRecord{}, err
+	}
+
+	if p.dryRun(ctx) {
+		return zoneInfo.record(record), nil
+	}
+
+	jsonBytes, err := json.Marshal(zoneInfo.record(record))
+	if err != nil {
+		return netlifyDNSRecord{}, err
+	}
+	reqCtx := ctx
+	if p.DeduplicateCreates {
+		// a retried create may have been applied by Netlify before
+		// the previous attempt failed; look for it before sending
+		// the request again
+		reqCtx = withRetryCheck(ctx, func() ([]byte, bool) {
+			matches, err := p.getDNSRecords(ctx, zoneInfo, record, true)
+			if err != nil || len(matches) == 0 {
+				return nil, false
+			}
+			body, err := json.Marshal(matches[0])
+			return body, err == nil
+		})
+	}
+
+	reqURL := fmt.Sprintf("%s/dns_zones/%s/dns_records", p.baseURL(), zoneInfo.ID)
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, reqURL, bytes.NewReader(jsonBytes))
 	if err != nil {
 		return netlifyDNSRecord{}, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if p.DeduplicateCreates {
+		req.Header.Set("Idempotency-Key", newIdempotencyKey())
+	}
 
 	var result netlifyDNSRecord
-	err = p.doAPIRequest(req, false, false, false, true, &result)
+	err = p.doAPIRequest(req, zoneInfo.ID, false, false, false, true, &result)
 	if err != nil {
 		return netlifyDNSRecord{}, err
 	}
+	if result.DNSRecord == nil {
+		result = zoneInfo.record(record)
+	}
+	if result.TTL == 0 {
+		// report the TTL which was sent if Netlify didn't
+		result.TTL = ttlSeconds(record.TTL)
+	}
+	if result.ID == "" && !p.AllowMissingRecordID {
+		return netlifyDNSRecord{}, fmt.Errorf("%s record %s: %w", record.Type, record.Name, ErrMissingRecordID)
+	}
 
 	return result, nil
 }
 
+// checkCNAMEConflict looks for records which can't coexist with record, that
+// is a CNAME record and another record with the same name. It returns nil if
+// there is no conflict or if CNAMEConflicts is set to "warn", the error
+// otherwise
+func (p *Provider) checkCNAMEConflict(ctx context.Context, zoneInfo netlifyZone, record libdns.Record) error {
+	if p.CNAMEConflicts != "warn" && p.CNAMEConflicts != "error" {
+		return nil
+	}
+
+	existing, err := p.getRecordsNamed(ctx, zoneInfo, record.Name)
+	if err != nil {
+		return err
+	}
+
+	isCNAME := strings.EqualFold(record.Type, "CNAME")
+	for _, rec := range existing {
+		if isCNAME == strings.EqualFold(rec.Type, "CNAME") {
+			continue
+		}
+		if p.CNAMEConflicts == "warn" {
+			p.logger().Warn("record conflicts with an existing record",
+				"name", record.Name, "type", record.Type, "existing_type", rec.Type)
+			return nil
+		}
+		return fmt.Errorf("%s record %s conflicts with existing %s record: %w", record.Type, record.Name, rec.Type, ErrCNAMEConflict)
+	}
+	return nil
+}
+
 // updateRecord updates a DNS record. oldRec must have both an ID and zone ID.
 // Only the non-empty fields in newRec will be changed.
 func (p *Provider) updateRecord(ctx context.Context, oldRec netlifyDNSRecord, newRec netlifyDNSRecord) (netlifyDNSRecord, error) {
-	reqURL := fmt.Sprintf("%s/dns_zones/%s/dns_records/%s", baseURL, oldRec.DNSZoneID, oldRec.ID)
+	if p.dryRun(ctx) {
+		result := *newRec.DNSRecord
+		result.ID = oldRec.ID
+		result.DNSZoneID = oldRec.DNSZoneID
+		return netlifyDNSRecord{DNSRecord: &result, Weight: newRec.Weight, Port: newRec.Port}, nil
+	}
+
+	reqURL := fmt.Sprintf("%s/dns_zones/%s/dns_records/%s", p.baseURL(), oldRec.DNSZoneID, oldRec.ID)
 	jsonBytes, err := json.Marshal(newRec)
 	if err != nil {
 		return netlifyDNSRecord{}, err
@@ -52,107 +147,537 @@ func (p *Provider) updateRecord(ctx context.Context, oldRec netlifyDNSRecord, ne
 	req.Header.Set("Content-Type", "application/json")
 
 	var result netlifyDNSRecord
-	err = p.doAPIRequest(req, false, false, false, true, &result)
-	return result, err
+	err = p.doAPIRequest(req, oldRec.DNSZoneID, false, false, false, true, &result)
+	if err != nil {
+		return netlifyDNSRecord{}, err
+	}
+	if result.DNSRecord == nil {
+		// no record in the response; fetch the updated record
+		return p.getDNSRecord(ctx, oldRec.DNSZoneID, oldRec.ID)
+	}
+	return result, nil
 }
 
-// getDNSRecords gets all record in a zone. It returns an array of the records
-// in the zone
-func (p *Provider) getDNSRecords(ctx context.Context, zoneInfo netlifyZone, rec libdns.Record, matchContent bool) ([]netlifyDNSRecord, error) {
-	qs := make(url.Values)
-	qs.Set("type", rec.Type)
-	qs.Set("name", libdns.AbsoluteName(rec.Name, zoneInfo.Name))
-	if matchContent {
-		qs.Set("content", rec.Value)
+// deleteRecord deletes the record rec, which must have an ID, from the zone.
+// A record Netlify doesn't find was already deleted, such as by a concurrent
+// cleanup; deleteRecord then returns false and no error
+func (p *Provider) deleteRecord(ctx context.Context, zoneInfo netlifyZone, zone string, rec netlifyDNSRecord) (bool, error) {
+	if err := p.checkNSChange(zoneInfo, rec); err != nil {
+		return false, err
+	}
+	if err := p.beforeMutate(ctx, OpDelete, rec.libdnsRecord(zone)); err != nil {
+		return false, err
+	}
+	if p.dryRun(ctx) {
+		return true, nil
+	}
+
+	reqURL := fmt.Sprintf("%s/dns_zones/%s/dns_records/%s", p.baseURL(), zoneInfo.ID, rec.ID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, reqURL, nil)
+	if err != nil {
+		return false, err
+	}
+	err = p.doAPIRequest(req, zoneInfo.ID, false, true, false, true, nil)
+	if err == nil {
+		return true, nil
 	}
+	missing, err := p.recordMissing(ctx, zoneInfo, err)
+	if missing {
+		p.logger().Debug("record already deleted",
+			"name", rec.Hostname, "type", rec.Type, "id", rec.ID)
+	}
+	return false, err
+}
 
-	reqURL := fmt.Sprintf("%s/dns_zones/%s/dns_records", baseURL, zoneInfo.ID)
+// recordMissing reports whether err, returned by a request on a single
+// record of the zone, means that the record doesn't exist. As Netlify
+// answers 404 Not Found for a missing zone too, the zone is looked up then;
+// the error wraps ErrZoneNotFound if it is missing. It returns err itself
+// for any other error
+func (p *Provider) recordMissing(ctx context.Context, zoneInfo netlifyZone, err error) (bool, error) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		return false, err
+	}
+
+	reqURL := fmt.Sprintf("%s/dns_zones/%s", p.baseURL(), zoneInfo.ID)
+	req, reqErr := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if reqErr != nil {
+		return false, reqErr
+	}
+	var zone json.RawMessage
+	zoneErr := p.doAPIRequest(req, zoneInfo.ID, true, false, true, true, &zone)
+	if errors.As(zoneErr, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return false, fmt.Errorf("%s: %w", zoneInfo.Name, ErrZoneNotFound)
+	}
+	if zoneErr != nil {
+		return false, zoneErr
+	}
+	return true, nil
+}
+
+// getDNSRecord gets a single record of a zone by its ID. It returns the record
+func (p *Provider) getDNSRecord(ctx context.Context, zoneID string, recordID string) (netlifyDNSRecord, error) {
+	reqURL := fmt.Sprintf("%s/dns_zones/%s/dns_records/%s", p.baseURL(), zoneID, recordID)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
 	if err != nil {
-		return nil, err
+		return netlifyDNSRecord{}, err
+	}
+
+	var result netlifyDNSRecord
+	err = p.doAPIRequest(req, zoneID, false, false, true, true, &result)
+	if err != nil {
+		return netlifyDNSRecord{}, err
+	}
+	if result.DNSRecord == nil {
+		return netlifyDNSRecord{}, fmt.Errorf("can't find DNS record %s: %w", recordID, ErrRecordNotFound)
+	}
+	return result, nil
+}
+
+// recordsSortOrder is the sort parameter requesting the records of a zone
+// sorted by type, name and value, as SortRecords sorts them
+const recordsSortOrder = "type,hostname,value"
+
+// listDNSRecords gets all the records in a zone, from the records cache if
+// possible. It returns an array of the records in the zone. If a page of the
+// records can't be fetched, it returns the records of the previous pages
+// with the error
+func (p *Provider) listDNSRecords(ctx context.Context, zoneInfo netlifyZone) ([]netlifyDNSRecord, error) {
+	var results []netlifyDNSRecord
+	err := p.pageDNSRecords(ctx, zoneInfo, func(records []netlifyDNSRecord) error {
+		results = append(results, records...)
+		return nil
+	})
+	return results, err
+}
+
+// pageDNSRecords gets the records in a zone page after page, passing each
+// page to page, or all the records at once if they are in the records cache.
+// The records are cached once all the pages have been fetched
+func (p *Provider) pageDNSRecords(ctx context.Context, zoneInfo netlifyZone, page func([]netlifyDNSRecord) error) error {
+	// a zone found in a parent zone only has the records under its name
+	zoneName := normalizeName(zoneInfo.Name, "")
+	inZone := func(records []netlifyDNSRecord) []netlifyDNSRecord {
+		var kept []netlifyDNSRecord
+		for _, rec := range records {
+			name := normalizeName(rec.Hostname, "")
+			if zoneName == "" || name == zoneName || strings.HasSuffix(name, "."+zoneName) {
+				kept = append(kept, rec)
+			}
+		}
+		return kept
+	}
+
+	key, err := p.cacheKey(ctx, zoneInfo.ID)
+	if err != nil {
+		return err
+	}
+	if records, ok := p.getCachedRecords(key); ok {
+		return page(inZone(records))
 	}
 
 	var results []netlifyDNSRecord
-	err = p.doAPIRequest(req, false, false, true, false, &results)
+	reqURL := fmt.Sprintf("%s/dns_zones/%s/dns_records", p.baseURL(), zoneInfo.ID)
+	if p.SortRecords {
+		reqURL += "?sort=" + url.QueryEscape(recordsSortOrder)
+	}
+	err = p.listPages(ctx, reqURL, zoneInfo.ID, false, func(items []json.RawMessage) error {
+		records := make([]netlifyDNSRecord, 0, len(items))
+		for _, item := range items {
+			var rec netlifyDNSRecord
+			if err := json.Unmarshal(item, &rec); err != nil {
+				return err
+			}
+			records = append(records, rec)
+		}
+		results = append(results, records...)
+		return page(inZone(records))
+	})
+	if err != nil {
+		return err
+	}
+
+	p.cacheRecords(key, results)
+	return nil
+}
+
+// getDNSRecords gets all record in a zone. It returns an array of the records
+// in the zone matching rec, or an error wrapping ErrRecordNotFound if there is
+// none
+func (p *Provider) getDNSRecords(ctx context.Context, zoneInfo netlifyZone, rec libdns.Record, matchContent bool) ([]netlifyDNSRecord, error) {
+	// Netlify's API can't filter records, so they are all listed and
+	// matched here, whether or not the names have a trailing dot
+	results, err := p.listDNSRecords(ctx, zoneInfo)
+	if err != nil {
+		return nil, err
+	}
 	var rest_to_return []netlifyDNSRecord
 	for _, res := range results {
-		if res.Hostname == libdns.AbsoluteName(rec.Name, zoneInfo.Name) && res.Type == rec.Type {
-			rest_to_return = append(rest_to_return, res)
+		if normalizeName(res.Hostname, "") != normalizeName(rec.Name, zoneInfo.Name) {
+			continue
 		}
+		if !strings.EqualFold(res.Type, rec.Type) {
+			continue
+		}
+		// values are compared exactly as TXT records are case-sensitive
+		if matchContent && !sameValue(res, rec) {
+			continue
+		}
+		rest_to_return = append(rest_to_return, res)
 	}
 	if len(rest_to_return) == 0 {
-		return nil, fmt.Errorf("Can't find DNS record %s", libdns.AbsoluteName(rec.Name, zoneInfo.Name))
+		return nil, fmt.Errorf("can't find DNS record %s: %w", normalizeName(rec.Name, zoneInfo.Name), ErrRecordNotFound)
 	}
+	return rest_to_return, nil
+}
+
+// getRecordsNamed returns the records of any type in a zone with the given
+// name, which may be none
+func (p *Provider) getRecordsNamed(ctx context.Context, zoneInfo netlifyZone, name string) ([]netlifyDNSRecord, error) {
+	results, err := p.listDNSRecords(ctx, zoneInfo)
 	if err != nil {
 		return nil, err
 	}
-	return rest_to_return, nil
+	var named []netlifyDNSRecord
+	for _, res := range results {
+		if normalizeName(res.Hostname, "") == normalizeName(name, zoneInfo.Name) {
+			named = append(named, res)
+		}
+	}
+	return named, nil
 }
 
-// getZoneInfo get the information from a DNS zone. It returns the dns zone
+// getZoneInfo get the information from a DNS zone. It returns the dns zone,
+// or a zone with the ID set by WithZoneID
 func (p *Provider) getZoneInfo(ctx context.Context, zoneName string) (netlifyZone, error) {
+	// the caller already knows the zone ID, no need to look it up
+	if zoneID, ok := ctx.Value(zoneIDKey{}).(string); ok && zoneID != "" {
+		return netlifyZone{DNSZone: &models.DNSZone{ID: zoneID, Name: strings.TrimSuffix(zoneName, ".")}}, nil
+	}
+
+	key, err := p.cacheKey(ctx, zoneName)
+	if err != nil {
+		return netlifyZone{}, err
+	}
+
 	p.zonesMu.Lock()
 	defer p.zonesMu.Unlock()
 
-	// if we already got the zone info, reuse it
+	// if we already got the zone info for this token, reuse it
 	if p.zones == nil {
-		p.zones = make(map[string]netlifyZone)
+		p.zones = make(map[cacheKey]netlifyZone)
 	}
-	if zone, ok := p.zones[zoneName]; ok {
+	if zone, ok := p.zones[key]; ok {
+		p.cacheCounters.zoneHits.Add(1)
 		return zone, nil
 	}
+	p.cacheCounters.zoneMisses.Add(1)
 
 	qs := make(url.Values)
-	qs.Set("name", zoneName)
-	reqURL := fmt.Sprintf("%s/dns_zones?%s", baseURL, qs.Encode())
+	qs.Set("name", toASCIIName(zoneName))
+	reqURL := fmt.Sprintf("%s/dns_zones?%s", p.baseURL(), qs.Encode())
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
 	if err != nil {
 		return netlifyZone{}, err
 	}
 
+	var raw json.RawMessage
+	err = p.doAPIRequest(req, "", true, false, true, false, &raw)
+	if err != nil {
+		return netlifyZone{}, err
+	}
 	var zones []netlifyZone
-	err = p.doAPIRequest(req, true, false, true, false, &zones)
+	err = json.Unmarshal(raw, &zones)
 	if err != nil {
 		return netlifyZone{}, err
 	}
-	if len(zones) != 1 {
-		return netlifyZone{}, fmt.Errorf("expected 1 zone, got %d for %s", len(zones), zoneName)
+	zone, ok := longestSuffixZone(zoneName, zones)
+	if !ok || len(zones) != 1 {
+		names := make([]string, 0, len(zones))
+		for _, zone := range zones {
+			names = append(names, zone.Name)
+		}
+		p.logger().Debug("unexpected zone lookup result",
+			"zone", zoneName, "zones", names, "response", string(raw))
+	}
+	if !ok {
+		// the name may be in a zone with a shorter name, such as
+		// sub.example.com in example.com
+		zones, err = p.listAllZones(ctx)
+		if err != nil {
+			return netlifyZone{}, err
+		}
+		zone, ok = longestSuffixZone(zoneName, zones)
+		if !ok {
+			return netlifyZone{}, fmt.Errorf("%s: %w", zoneName, ErrZoneNotFound)
+		}
+	}
+
+	if normalizeName(zone.Name, "") != normalizeName(zoneName, "") {
+		// names are relative to the requested zone, not to the one
+		// holding it
+		p.logger().Debug("using parent zone",
+			"zone", zoneName, "parent", zone.Name, "id", zone.ID)
+		dnsZone := *zone.DNSZone
+		dnsZone.Name = strings.TrimSuffix(zoneName, ".")
+		zone.DNSZone = &dnsZone
+		zone.inParent = true
 	}
 
 	// cache this zone for possible reuse
-	p.zones[zoneName] = zones[0]
+	p.zones[key] = zone
 
-	return zones[0], nil
+	return zone, nil
 }
 
-// doAPIRequest authenticates the request req and does the round trip. It returns
-// nil if there was no error, the error otherwise. The decoded content is passed
-// to the calling function by the result variable
-func (p *Provider) doAPIRequest(req *http.Request, isZone bool, isDel bool, isGet bool, isSolo bool, result interface{}) error {
-	req.Header.Set("Authorization", "Bearer "+p.PersonnalAccessToken)
+// listAllZones gets all the DNS zones the access token can manage
+func (p *Provider) listAllZones(ctx context.Context) ([]netlifyZone, error) {
+	var zones []netlifyZone
+	reqURL := fmt.Sprintf("%s/dns_zones", p.baseURL())
+	err := p.listPages(ctx, reqURL, "", true, func(items []json.RawMessage) error {
+		for _, item := range items {
+			var zone netlifyZone
+			if err := json.Unmarshal(item, &zone); err != nil {
+				return err
+			}
+			zones = append(zones, zone)
+		}
+		return nil
+	})
+	return zones, err
+}
 
-	resp, err := http.DefaultClient.Do(req)
+// longestSuffixZone returns the zone of zones with the longest name which is
+// name or a parent domain of it. It returns false if there is none
+func longestSuffixZone(name string, zones []netlifyZone) (netlifyZone, bool) {
+	name = normalizeName(name, "")
+	var best netlifyZone
+	var bestName string
+	for _, zone := range zones {
+		if zone.DNSZone == nil {
+			continue
+		}
+		zoneName := normalizeName(zone.Name, "")
+		if name != zoneName && !strings.HasSuffix(name, "."+zoneName) {
+			continue
+		}
+		if best.DNSZone == nil || len(zoneName) > len(bestName) {
+			best, bestName = zone, zoneName
+		}
+	}
+	return best, best.DNSZone != nil
+}
+
+// getWritableZoneInfo gets the information from a DNS zone which is about to
+// be changed. It returns an error wrapping ErrZoneInactive if the zone isn't
+// active
+func (p *Provider) getWritableZoneInfo(ctx context.Context, zoneName string) (netlifyZone, error) {
+	zoneInfo, err := p.getZoneInfo(ctx, zoneName)
 	if err != nil {
-		return err
+		return netlifyZone{}, err
+	}
+	if !zoneInfo.active() {
+		return netlifyZone{}, fmt.Errorf("%s is %s: %w", zoneName, zoneInfo.Status, ErrZoneInactive)
+	}
+	return zoneInfo, nil
+}
+
+// readBody reads body until EOF. It returns a *ResponseTooLargeError if body
+// is larger than limit bytes, and the context error if ctx is done before the
+// whole body has been read, closing conn to stop the read. conn is the
+// underlying response body when body decodes it
+func readBody(ctx context.Context, body io.Reader, conn io.Closer, limit int64) ([]byte, error) {
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		data, err := ioutil.ReadAll(io.LimitReader(body, limit+1))
+		if err == nil && int64(len(data)) > limit {
+			err = &ResponseTooLargeError{Limit: limit}
+		}
+		done <- result{data, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.data, res.err
+	case <-ctx.Done():
+		conn.Close()
+		return nil, ctx.Err()
+	}
+}
+
+// hasErrorField reports whether body is a JSON object with a non-empty error
+// field, which Netlify may send along a successful status
+func hasErrorField(body []byte) bool {
+	var payload struct {
+		Error interface{} `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) != nil {
+		return false
+	}
+	switch e := payload.Error.(type) {
+	case nil:
+		return false
+	case string:
+		return e != ""
+	case bool:
+		return e
+	default:
+		return true
+	}
+}
+
+// sendRequest does a single round trip of req. It returns the response body
+// if the request succeeded, a *NetworkError or *APIError otherwise
+func (p *Provider) sendRequest(req *http.Request, zoneID string) ([]byte, error) {
+	releaseWrite, err := p.acquireWriteSlot(req.Context(), req.Method)
+	if err != nil {
+		return nil, err
+	}
+	defer releaseWrite()
+
+	token := requestToken(req)
+	if err := p.waitRateLimit(req.Context(), token, zoneID); err != nil {
+		return nil, err
+	}
+	if err := p.waitRateTracker(req.Context(), token); err != nil {
+		return nil, err
+	}
+
+	release, err := p.acquireRequestSlot(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// any change to the records of a zone makes its cached records stale
+	if req.Method != http.MethodGet && zoneID != "" {
+		defer p.invalidateRecords(zoneID)
+	}
+
+	parent := req.Context()
+	if p.RequestTimeout > 0 {
+		ctx, cancel := context.WithTimeout(parent, p.RequestTimeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+
+	client, err := p.httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// setting Accept-Encoding disables the transparent decompression of
+	// the transport, so that compression works with any transport
+	if !p.DisableCompression {
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	p.setConditionalHeaders(req)
+
+	req, reportTiming := p.traceRequest(req)
+	resp, err := client.Do(req)
+	reportTiming()
+	if err != nil {
+		return nil, p.networkError(parent, err)
 	}
 	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
+	p.tracker(token).update(resp.Header)
+	if header, ok := req.Context().Value(responseHeaderKey{}).(*http.Header); ok {
+		*header = resp.Header
+	}
+	p.logger().Debug("netlify API response",
+		"method", req.Method, "url", req.URL.String(), "status", resp.StatusCode,
+		"request_id", resp.Header.Get(requestIDHeader))
+
+	var respBody io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, p.networkError(parent, err)
+		}
+		defer gz.Close()
+		respBody = gz
+	}
+
+	body, err := readBody(req.Context(), respBody, resp.Body, p.maxResponseSize())
+	if err != nil {
+		var tooLarge *ResponseTooLargeError
+		if errors.As(err, &tooLarge) {
+			return nil, err
+		}
+		return nil, p.networkError(parent, err)
+	}
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("got error status: HTTP %d: %+v", resp.StatusCode, string(body))
+		apiErr := newAPIError(resp, body)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			apiErr.RetryAfter = retryAfter(resp.Header, p.clock().Now())
+		}
+		return nil, apiErr
 	}
 
-	// delete DNS record
-	if isDel && !isZone {
-		if len(body) > 0 {
-			var err netlifyDNSDeleteError
-			json.Unmarshal(body, &result)
-			return fmt.Errorf(err.Message)
+	body = p.conditionalBody(req, resp, body)
+
+	if p.CheckResponseErrors && hasErrorField(body) {
+		return nil, newAPIError(resp, body)
+	}
+
+	return body, nil
+}
+
+// networkError returns the error for a request which couldn't complete. If
+// only the RequestTimeout elapsed, the error doesn't wrap the context error,
+// so that the request may be retried within the parent context
+func (p *Provider) networkError(parent context.Context, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) && parent.Err() == nil {
+		return &NetworkError{Err: fmt.Errorf("request timed out after %s", p.RequestTimeout)}
+	}
+	return &NetworkError{Err: err}
+}
+
+// doAPIRequest authenticates the request req and does the round trip. It returns
+// nil if there was no error, the error otherwise. The decoded content is passed
+// to the calling function by the result variable. zoneID is the ID of the zone
+// the request applies to, if any, and is used for per-zone rate limiting
+func (p *Provider) doAPIRequest(req *http.Request, zoneID string, isZone bool, isDel bool, isGet bool, isSolo bool, result interface{}) error {
+	token, err := p.token(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("User-Agent", p.userAgent())
+	if id, ok := req.Context().Value(correlationIDKey{}).(string); ok && id != "" {
+		req.Header.Set(correlationIDHeader, id)
+	}
+
+	body, err := p.sendWithRetries(req, zoneID)
+	if IsAuthError(err) {
+		// the token may have been rotated since it was read
+		if fresh, ok := p.refreshToken(req.Context(), token); ok {
+			req.Header.Set("Authorization", "Bearer "+fresh)
+			if req.GetBody != nil {
+				if req.Body, err = req.GetBody(); err != nil {
+					return err
+				}
+			}
+			body, err = p.sendWithRetries(req, zoneID)
 		}
+	}
+	if err != nil {
 		return err
 	}
 
+	// delete DNS record; errors were already reported by the status
+	if isDel && !isZone {
+		return nil
+	}
+
 	// get zone info
 	if isZone && isGet {
 		err = json.Unmarshal(body, &result)
@@ -182,9 +707,12 @@ func (p *Provider) doAPIRequest(req *http.Request, isZone bool, isDel bool, isGe
 
 	// update DNS record
 	if !isZone && isSolo && !isGet {
+		if len(body) == 0 {
+			return nil
+		}
 		err = json.Unmarshal(body, &result)
 		if err != nil {
-			return nil
+			return err
 		}
 		return err
 	}
@@ -192,4 +720,44 @@ func (p *Provider) doAPIRequest(req *http.Request, isZone bool, isDel bool, isGe
 	return err
 }
 
-const baseURL = "https://api.netlify.com/api/v1"
+const (
+	// requestIDHeader is the response header with the ID Netlify gave to
+	// the request
+	requestIDHeader = "X-Nf-Request-Id"
+	// correlationIDHeader is the request header with the ID set with
+	// WithCorrelationID
+	correlationIDHeader = "X-Correlation-Id"
+)
+
+// defaultMaxResponseSize is the maximum size of a response body when
+// MaxResponseSize isn't set
+const defaultMaxResponseSize = 10 << 20
+
+// maxResponseSize returns the maximum size of a response body, in bytes
+func (p *Provider) maxResponseSize() int64 {
+	if p.MaxResponseSize > 0 {
+		return p.MaxResponseSize
+	}
+	return defaultMaxResponseSize
+}
+
+// baseURL returns the base URL of Netlify's API, without a trailing slash
+func (p *Provider) baseURL() string {
+	if p.BaseURL != "" {
+		return strings.TrimSuffix(p.BaseURL, "/")
+	}
+	return defaultBaseURL + p.apiVersion()
+}
+
+// apiVersion returns the version of Netlify's API to use
+func (p *Provider) apiVersion() string {
+	if p.APIVersion != "" {
+		return p.APIVersion
+	}
+	return defaultAPIVersion
+}
+
+const (
+	defaultBaseURL    = "https://api.netlify.com/api/"
+	defaultAPIVersion = "v1"
+)
@@ -0,0 +1,445 @@
+package netlify
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/netlify/open-api/v2/go/models"
+)
+
+func TestGetDNSRecordsNotFound(t *testing.T) {
+	api, p := newFakeAPI(t)
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: "192.0.2.1"})
+
+	ctx := context.Background()
+	zoneInfo, err := p.getZoneInfo(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rec := range []libdns.Record{
+		{Type: "A", Name: "missing"},
+		{Type: "AAAA", Name: "www"},
+	} {
+		_, err := p.getDNSRecords(ctx, zoneInfo, rec, false)
+		if !errors.Is(err, ErrRecordNotFound) {
+			t.Errorf("%s %s: got error %v, want ErrRecordNotFound", rec.Type, rec.Name, err)
+		}
+		if errors.Is(err, ErrZoneNotFound) {
+			t.Errorf("%s %s: got error %v, want it distinct from ErrZoneNotFound", rec.Type, rec.Name, err)
+		}
+	}
+
+	if _, err := p.getDNSRecords(ctx, zoneInfo, libdns.Record{Type: "A", Name: "www"}, false); err != nil {
+		t.Errorf("got error %v for an existing record", err)
+	}
+}
+
+func TestGetRecordsZoneNotFound(t *testing.T) {
+	_, p := newFakeAPI(t)
+
+	_, err := p.GetRecords(context.Background(), "example.org")
+	if !errors.Is(err, ErrZoneNotFound) || errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("got error %v, want ErrZoneNotFound only", err)
+	}
+}
+
+// omitCreatedIDs makes api answer record creations with a record without ID
+func omitCreatedIDs(api *fakeAPI) {
+	api.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPost {
+			return false
+		}
+		var rec netlifyDNSRecord
+		json.NewDecoder(r.Body).Decode(&rec)
+		writeJSON(w, http.StatusCreated, rec)
+		return true
+	}
+}
+
+func TestCreateRecordWithoutID(t *testing.T) {
+	api, p := newFakeAPI(t)
+	omitCreatedIDs(api)
+
+	_, err := p.AppendRecords(context.Background(), "example.com", []libdns.Record{
+		{Type: "A", Name: "www", Value: "192.0.2.1"},
+	})
+	if !errors.Is(err, ErrMissingRecordID) {
+		t.Errorf("got error %v, want ErrMissingRecordID", err)
+	}
+}
+
+func TestCreateRecordWithoutIDAllowed(t *testing.T) {
+	api, p := newFakeAPI(t)
+	omitCreatedIDs(api)
+	p.AllowMissingRecordID = true
+
+	created, err := p.AppendRecords(context.Background(), "example.com", []libdns.Record{
+		{Type: "A", Name: "www", Value: "192.0.2.1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 1 || created[0].ID != "" || created[0].Value != "192.0.2.1" {
+		t.Errorf("got %+v, want the record without ID", created)
+	}
+}
+
+func TestConnectionRefused(t *testing.T) {
+	api, p := newFakeAPI(t)
+	api.Close()
+	clock := newFakeClock()
+	p.Clock = clock
+	p.MaxRetries = 2
+
+	_, err := p.GetRecords(context.Background(), "example.com")
+	var netErr *NetworkError
+	if !errors.As(err, &netErr) {
+		t.Fatalf("got error %v, want a *NetworkError", err)
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		t.Errorf("got error %v, want it not to be an *APIError", err)
+	}
+	if !IsRetryable(err) {
+		t.Errorf("got error %v, want it retryable", err)
+	}
+	if n := len(clock.waited()); n != 2 {
+		t.Errorf("retried %d times, want 2", n)
+	}
+}
+
+func TestServerError(t *testing.T) {
+	api, p := newFakeAPI(t)
+	clock := newFakeClock()
+	p.Clock = clock
+	p.MaxRetries = 2
+	api.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		http.Error(w, `{"code":500,"message":"Internal Server Error"}`, http.StatusInternalServerError)
+		return true
+	}
+
+	_, err := p.GetRecords(context.Background(), "example.com")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("got error %v, want an *APIError with status 500", err)
+	}
+	var netErr *NetworkError
+	if errors.As(err, &netErr) {
+		t.Errorf("got error %v, want it not to be a *NetworkError", err)
+	}
+	if !IsRetryable(err) {
+		t.Errorf("got error %v, want it retryable", err)
+	}
+	if n := api.countRequests(http.MethodGet, "/dns_zones"); n != 3 {
+		t.Errorf("sent %d requests, want 3", n)
+	}
+}
+
+func TestInactiveZone(t *testing.T) {
+	api, p := newFakeAPI(t)
+	api.mu.Lock()
+	api.zones[0].Status = "pending"
+	api.mu.Unlock()
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: "192.0.2.1"})
+
+	ctx := context.Background()
+	record := libdns.Record{Type: "A", Name: "www", Value: "192.0.2.2"}
+	if _, err := p.AppendRecords(ctx, "example.com", []libdns.Record{record}); !errors.Is(err, ErrZoneInactive) {
+		t.Errorf("AppendRecords: got error %v, want ErrZoneInactive", err)
+	}
+	if _, err := p.SetRecords(ctx, "example.com", []libdns.Record{record}); !errors.Is(err, ErrZoneInactive) {
+		t.Errorf("SetRecords: got error %v, want ErrZoneInactive", err)
+	}
+	if _, err := p.DeleteRecords(ctx, "example.com", []libdns.Record{record}); !errors.Is(err, ErrZoneInactive) {
+		t.Errorf("DeleteRecords: got error %v, want ErrZoneInactive", err)
+	}
+	for _, method := range []string{http.MethodPost, http.MethodPatch, http.MethodDelete} {
+		if n := api.countRequests(method, ""); n != 0 {
+			t.Errorf("sent %d %s requests to an inactive zone", n, method)
+		}
+	}
+
+	records, err := p.GetRecords(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Errorf("got %d records, want 1", len(records))
+	}
+}
+
+func TestCNAMEConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing models.DNSRecord
+		record   libdns.Record
+	}{
+		{
+			name:     "A shadows CNAME",
+			existing: models.DNSRecord{Type: "CNAME", Hostname: "www.example.com", Value: "target.example.net"},
+			record:   libdns.Record{Type: "A", Name: "www", Value: "192.0.2.1"},
+		},
+		{
+			name:     "CNAME shadows A",
+			existing: models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: "192.0.2.1"},
+			record:   libdns.Record{Type: "CNAME", Name: "www", Value: "target.example.net"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" error", func(t *testing.T) {
+			api, p := newFakeAPI(t)
+			p.CNAMEConflicts = "error"
+			api.addRecord("zone1", tt.existing)
+
+			_, err := p.AppendRecords(context.Background(), "example.com", []libdns.Record{tt.record})
+			if !errors.Is(err, ErrCNAMEConflict) {
+				t.Errorf("got error %v, want ErrCNAMEConflict", err)
+			}
+			if n := api.countRequests(http.MethodPost, ""); n != 0 {
+				t.Errorf("sent %d create requests, want none", n)
+			}
+		})
+		t.Run(tt.name+" warn", func(t *testing.T) {
+			api, p := newFakeAPI(t)
+			logger := &testLogger{}
+			p.CNAMEConflicts = "warn"
+			p.Logger = logger
+			api.addRecord("zone1", tt.existing)
+
+			if _, err := p.AppendRecords(context.Background(), "example.com", []libdns.Record{tt.record}); err != nil {
+				t.Fatal(err)
+			}
+			if len(logger.logged("WARN", "conflicts")) != 1 {
+				t.Errorf("logged %q, want a conflict warning", logger.messages)
+			}
+			if n := len(api.zoneRecords("zone1")); n != 2 {
+				t.Errorf("zone has %d records, want 2", n)
+			}
+		})
+	}
+}
+
+func TestCNAMEConflictsOtherNames(t *testing.T) {
+	api, p := newFakeAPI(t)
+	p.CNAMEConflicts = "error"
+	api.addRecord("zone1", models.DNSRecord{Type: "CNAME", Hostname: "www.example.com", Value: "target.example.net"})
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "mail.example.com", Value: "192.0.2.1"})
+
+	_, err := p.AppendRecords(context.Background(), "example.com", []libdns.Record{
+		{Type: "A", Name: "api", Value: "192.0.2.2"},
+		{Type: "CNAME", Name: "blog", Value: "target.example.net"},
+	})
+	if err != nil {
+		t.Errorf("got error %v for records without conflict", err)
+	}
+}
+
+func TestCheckResponseErrors(t *testing.T) {
+	for _, check := range []bool{false, true} {
+		t.Run(fmt.Sprint(check), func(t *testing.T) {
+			api, p := newFakeAPI(t)
+			p.CheckResponseErrors = check
+			rec := api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: "192.0.2.1"})
+			api.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+				if !strings.HasSuffix(r.URL.Path, "/dns_records/"+rec.ID) {
+					return false
+				}
+				w.Write([]byte(`{"id":"` + rec.ID + `","type":"A","hostname":"www.example.com","value":"192.0.2.1","error":"record is locked"}`))
+				return true
+			}
+
+			_, err := p.GetRecordByID(context.Background(), "example.com", rec.ID)
+			if !check {
+				if err != nil {
+					t.Errorf("got error %v with CheckResponseErrors unset", err)
+				}
+				return
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusOK {
+				t.Fatalf("got error %v, want an *APIError with status 200", err)
+			}
+			if !strings.Contains(apiErr.Body, "record is locked") {
+				t.Errorf("got body %q, want the error field", apiErr.Body)
+			}
+		})
+	}
+}
+
+func TestHasErrorField(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{`{"error":"failed"}`, true},
+		{`{"error":true}`, true},
+		{`{"error":{"code":1}}`, true},
+		{`{"error":""}`, false},
+		{`{"error":false}`, false},
+		{`{"error":null}`, false},
+		{`{"id":"rec1"}`, false},
+		{`[{"error":"in a list"}]`, false},
+		{`not json`, false},
+	}
+	for _, tt := range tests {
+		if got := hasErrorField([]byte(tt.body)); got != tt.want {
+			t.Errorf("hasErrorField(%s) = %t, want %t", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestAmbiguousZoneLookupLogged(t *testing.T) {
+	api, p := newFakeAPI(t)
+	logger := &testLogger{}
+	p.Logger = logger
+	api.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Path != "/dns_zones" {
+			return false
+		}
+		writeJSON(w, http.StatusOK, []map[string]string{
+			{"id": "zone1", "name": "example.com"},
+			{"id": "zone9", "name": "example.com.au"},
+		})
+		return true
+	}
+
+	if _, err := p.GetRecords(context.Background(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	logged := logger.logged("DEBUG", "unexpected zone lookup result")
+	if len(logged) != 1 {
+		t.Fatalf("logged %q, want the ambiguous lookup", logger.messages)
+	}
+	if !strings.Contains(logged[0], "[example.com example.com.au]") {
+		t.Errorf("logged %q, want it to name both zones", logged[0])
+	}
+}
+
+func TestStalledBody(t *testing.T) {
+	api, p := newFakeAPI(t)
+	stalled := make(chan struct{})
+	t.Cleanup(func() { close(stalled) })
+	api.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if !strings.HasSuffix(r.URL.Path, "/dns_records") {
+			return false
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`[{"id":"rec1",`))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-stalled:
+		}
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := p.GetRecords(ctx, "example.com")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("returned after %s, want soon after the deadline", elapsed)
+	}
+}
+
+func TestReadBodyCanceled(t *testing.T) {
+	body, writer := io.Pipe()
+	defer writer.Close()
+	go writer.Write([]byte("partial"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := readBody(ctx, body, body, 1024); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+}
+
+func TestReadBodyCanceledGzip(t *testing.T) {
+	var compressed bytes.Buffer
+	gw := gzip.NewWriter(&compressed)
+	gw.Write([]byte(`[{"id":"rec1",`))
+	gw.Flush()
+
+	// the gzip stream stalls after its first block
+	body, writer := io.Pipe()
+	go writer.Write(compressed.Bytes())
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := readBody(ctx, gz, body, 1024); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want context.DeadlineExceeded", err)
+	}
+	// closing the response body unblocks the stalled read
+	if _, err := writer.Write([]byte("more")); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("got write error %v, want io.ErrClosedPipe", err)
+	}
+}
+
+func TestGetDNSRecordsNameForms(t *testing.T) {
+	api, p := newFakeAPI(t)
+	// Netlify returns hostnames without a trailing dot
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: "192.0.2.1"})
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "example.com", Value: "192.0.2.2"})
+
+	var queries []string
+	api.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if strings.HasSuffix(r.URL.Path, "/dns_records") {
+			queries = append(queries, r.URL.RawQuery)
+		}
+		return false
+	}
+
+	ctx := context.Background()
+	zoneInfo, err := p.getZoneInfo(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"www", "192.0.2.1"},
+		{"www.example.com.", "192.0.2.1"},
+		{"WWW.Example.COM.", "192.0.2.1"},
+		{"", "192.0.2.2"},
+		{"@", "192.0.2.2"},
+		{"example.com.", "192.0.2.2"},
+	}
+	for _, tt := range tests {
+		matches, err := p.getDNSRecords(ctx, zoneInfo, libdns.Record{Type: "A", Name: tt.name}, false)
+		if err != nil {
+			t.Errorf("%q: %v", tt.name, err)
+			continue
+		}
+		if len(matches) != 1 || matches[0].Value != tt.want {
+			t.Errorf("%q: got %+v, want the record with value %s", tt.name, matches, tt.want)
+		}
+	}
+
+	// the names are matched by the provider, not by the API
+	for _, query := range queries {
+		if strings.Contains(query, "name=") || strings.Contains(query, "type=") {
+			t.Errorf("sent the record filter %q", query)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package netlify
+
+import "time"
+
+// Clock is the source of time used by the provider for rate limiting, retries
+// and caching. It can be replaced to make time-dependent behavior
+// deterministic.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// clock returns the Clock of the provider, defaulting to the real time
+func (p *Provider) clock() Clock {
+	if p.Clock != nil {
+		return p.Clock
+	}
+	return realClock{}
+}
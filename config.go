@@ -0,0 +1,253 @@
+package netlify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// apiVersionPattern matches the versions of Netlify's API, such as v1
+var apiVersionPattern = regexp.MustCompile(`^v[0-9]+$`)
+
+// DebugConfig returns a human readable dump of the effective settings of the
+// provider, suitable for bug reports. The access token is never included
+func (p *Provider) DebugConfig() string {
+	var b strings.Builder
+
+	token := "<empty>"
+	if p.accessToken() != "" {
+		token = "<redacted>"
+	}
+	if p.usesTokenFile() {
+		token = "<from " + p.TokenFile + ">"
+	}
+	if p.TokenSource != nil {
+		token = "<token source>"
+	}
+
+	fmt.Fprintf(&b, "base_url: %s\n", p.baseURL())
+	fmt.Fprintf(&b, "api_version: %s\n", p.apiVersion())
+	fmt.Fprintf(&b, "api_token: %s\n", token)
+	zoneTokens := make([]string, 0, len(p.ZoneTokens))
+	for suffix := range p.ZoneTokens {
+		zoneTokens = append(zoneTokens, suffix)
+	}
+	sort.Strings(zoneTokens)
+	fmt.Fprintf(&b, "zone_tokens: %v\n", zoneTokens)
+	fmt.Fprintf(&b, "token_resolver: %t\n", p.TokenResolver != nil)
+	proxy := "<none>"
+	if p.ProxyURL != "" {
+		proxy = "<invalid>"
+		if u, err := url.Parse(p.ProxyURL); err == nil {
+			proxy = u.Redacted()
+		}
+		if p.ProxyUsername != "" {
+			proxy += " (with credentials)"
+		}
+	}
+	fmt.Fprintf(&b, "proxy_url: %s\n", proxy)
+	fmt.Fprintf(&b, "custom_tls_config: %t\n", p.TLSConfig != nil)
+	fmt.Fprintf(&b, "ca_bundle: %s\n", p.CABundle)
+	fmt.Fprintf(&b, "tls_min_version: %s\n", p.TLSMinVersion)
+	fmt.Fprintf(&b, "page_size: %d\n", p.pageSize())
+	fmt.Fprintf(&b, "max_response_size: %d\n", p.maxResponseSize())
+	fmt.Fprintf(&b, "disable_compression: %t\n", p.DisableCompression)
+	fmt.Fprintf(&b, "user_agent: %s\n", p.userAgent())
+	fmt.Fprintf(&b, "request_timeout: %s\n", p.RequestTimeout)
+	fmt.Fprintf(&b, "operation_timeout: %s\n", p.OperationTimeout)
+	fmt.Fprintf(&b, "max_retries: %d\n", p.MaxRetries)
+	fmt.Fprintf(&b, "deduplicate_creates: %t\n", p.DeduplicateCreates)
+	fmt.Fprintf(&b, "retry_backoff: %s\n", ExponentialBackoff{Base: p.RetryBackoff}.ceiling(1))
+	fmt.Fprintf(&b, "custom_retry_policy: %t\n", p.RetryPolicy != nil)
+	fmt.Fprintf(&b, "circuit_breaker_threshold: %d\n", p.CircuitBreakerThreshold)
+	fmt.Fprintf(&b, "circuit_breaker_cooldown: %s\n", p.CircuitBreakerCooldown)
+	fmt.Fprintf(&b, "rate_limit: %g\n", p.RateLimit)
+	fmt.Fprintf(&b, "share_rate_limit: %t\n", p.ShareRateLimit)
+	fmt.Fprintf(&b, "zone_rate_limit: %g\n", p.ZoneRateLimit)
+	fmt.Fprintf(&b, "max_concurrent_requests: %d\n", p.MaxConcurrentRequests)
+	fmt.Fprintf(&b, "min_write_interval: %s\n", p.MinWriteInterval)
+	fmt.Fprintf(&b, "default_ttl: %s\n", p.DefaultTTL)
+	fmt.Fprintf(&b, "min_ttl: %s\n", p.MinTTL)
+	fmt.Fprintf(&b, "reject_low_ttl: %t\n", p.RejectLowTTL)
+	fmt.Fprintf(&b, "skip_ip_validation: %t\n", p.SkipIPValidation)
+	fmt.Fprintf(&b, "strict_validation: %t\n", p.StrictValidation)
+	fmt.Fprintf(&b, "cname_conflicts: %s\n", p.CNAMEConflicts)
+	fmt.Fprintf(&b, "allow_missing_record_id: %t\n", p.AllowMissingRecordID)
+	fmt.Fprintf(&b, "allow_managed_record_changes: %t\n", p.AllowManagedRecordChanges)
+	fmt.Fprintf(&b, "allow_dangerous_ns_changes: %t\n", p.AllowDangerousNSChanges)
+	fmt.Fprintf(&b, "check_response_errors: %t\n", p.CheckResponseErrors)
+	fmt.Fprintf(&b, "records_cache_ttl: %s\n", p.RecordsCacheTTL)
+	fmt.Fprintf(&b, "conditional_requests: %t\n", p.ConditionalRequests)
+	fmt.Fprintf(&b, "trace_requests: %t\n", p.TraceRequests)
+	fmt.Fprintf(&b, "sort_records: %t\n", p.SortRecords)
+	fmt.Fprintf(&b, "dry_run: %t\n", p.DryRun)
+
+	return b.String()
+}
+
+// maskToken returns token with all but its prefix and last 4 characters
+// masked, such as "nfp_****1234"
+func maskToken(token string) string {
+	if token == "" {
+		return ""
+	}
+	prefix := ""
+	if i := strings.Index(token, "_"); i >= 0 && i < len(token)-1 {
+		prefix, token = token[:i+1], token[i+1:]
+	}
+	if len(token) <= 8 {
+		return prefix + "****"
+	}
+	return prefix + "****" + token[len(token)-4:]
+}
+
+// String returns the settings of the provider in JSON, with the credentials
+// masked as by MarshalJSON.
+func (p *Provider) String() string {
+	data, err := p.redactedJSON()
+	if err != nil {
+		return fmt.Sprintf("netlify.Provider{<%v>}", err)
+	}
+	return string(data)
+}
+
+// MarshalJSON encodes the settings of the provider with the access tokens and
+// proxy password masked, so that dumping the configuration doesn't leak
+// credentials. The result can't be used to authenticate once decoded.
+func (p *Provider) MarshalJSON() ([]byte, error) {
+	return p.redactedJSON()
+}
+
+// redactedJSON encodes the settings of the provider in JSON with the
+// credentials masked
+func (p *Provider) redactedJSON() ([]byte, error) {
+	type plain Provider
+	data, err := json.Marshal((*plain)(p))
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	// encoding a string can't fail
+	mask := func(key, value string) {
+		fields[key], _ = json.Marshal(value)
+	}
+	if p.PersonnalAccessToken != "" {
+		mask("api_token", maskToken(p.PersonnalAccessToken))
+	}
+	if p.ProxyPassword != "" {
+		mask("proxy_password", "****")
+	}
+	if p.ProxyURL != "" {
+		proxy := "<invalid>"
+		if u, err := url.Parse(p.ProxyURL); err == nil {
+			proxy = u.Redacted()
+		}
+		mask("proxy_url", proxy)
+	}
+	if len(p.ZoneTokens) > 0 {
+		zoneTokens := make(map[string]string, len(p.ZoneTokens))
+		for suffix, token := range p.ZoneTokens {
+			zoneTokens[suffix] = maskToken(token)
+		}
+		fields["zone_tokens"], err = json.Marshal(zoneTokens)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// ValidateConfig checks the settings of the provider without making any
+// request. It returns nil if they look usable, or an error joining every
+// problem found.
+func (p *Provider) ValidateConfig() error {
+	var errs []error
+
+	if p.usesTokenFile() {
+		if _, err := p.fileToken(false); err != nil {
+			errs = append(errs, fmt.Errorf("token_file: %w", err))
+		}
+	} else if p.accessToken() == "" && p.TokenSource == nil && p.TokenResolver == nil && len(p.ZoneTokens) == 0 {
+		errs = append(errs, errors.New("api_token: missing"))
+	}
+	if u, err := url.Parse(p.baseURL()); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("base_url: invalid URL %q", p.baseURL()))
+	}
+	if !apiVersionPattern.MatchString(p.apiVersion()) {
+		errs = append(errs, fmt.Errorf("api_version: invalid version %q", p.apiVersion()))
+	}
+	if p.ProxyURL != "" {
+		if _, err := p.proxyURL(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if p.TLSMinVersion != "" {
+		if _, err := parseTLSVersion(p.TLSMinVersion); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if p.PageSize < 0 {
+		errs = append(errs, fmt.Errorf("page_size: negative value %d", p.PageSize))
+	}
+	if p.MaxResponseSize < 0 {
+		errs = append(errs, fmt.Errorf("max_response_size: negative value %d", p.MaxResponseSize))
+	}
+	if p.RequestTimeout < 0 {
+		errs = append(errs, fmt.Errorf("request_timeout: negative duration %s", p.RequestTimeout))
+	}
+	if p.OperationTimeout < 0 {
+		errs = append(errs, fmt.Errorf("operation_timeout: negative duration %s", p.OperationTimeout))
+	}
+	if p.RequestTimeout > 0 && p.OperationTimeout > 0 && p.RequestTimeout > p.OperationTimeout {
+		errs = append(errs, fmt.Errorf("request_timeout: %s is longer than operation_timeout %s", p.RequestTimeout, p.OperationTimeout))
+	}
+	if p.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("max_retries: negative value %d", p.MaxRetries))
+	}
+	if p.RetryBackoff < 0 {
+		errs = append(errs, fmt.Errorf("retry_backoff: negative duration %s", p.RetryBackoff))
+	}
+	if p.CircuitBreakerThreshold < 0 {
+		errs = append(errs, fmt.Errorf("circuit_breaker_threshold: negative value %d", p.CircuitBreakerThreshold))
+	}
+	if p.CircuitBreakerCooldown < 0 {
+		errs = append(errs, fmt.Errorf("circuit_breaker_cooldown: negative duration %s", p.CircuitBreakerCooldown))
+	}
+	if p.RateLimit < 0 {
+		errs = append(errs, fmt.Errorf("rate_limit: negative value %g", p.RateLimit))
+	}
+	if p.ZoneRateLimit < 0 {
+		errs = append(errs, fmt.Errorf("zone_rate_limit: negative value %g", p.ZoneRateLimit))
+	}
+	if p.MaxConcurrentRequests < 0 {
+		errs = append(errs, fmt.Errorf("max_concurrent_requests: negative value %d", p.MaxConcurrentRequests))
+	}
+	if p.MinWriteInterval < 0 {
+		errs = append(errs, fmt.Errorf("min_write_interval: negative duration %s", p.MinWriteInterval))
+	}
+	if p.DefaultTTL < 0 {
+		errs = append(errs, fmt.Errorf("default_ttl: negative duration %s", p.DefaultTTL))
+	}
+	if p.MinTTL < 0 {
+		errs = append(errs, fmt.Errorf("min_ttl: negative duration %s", p.MinTTL))
+	}
+	if p.RecordsCacheTTL < 0 {
+		errs = append(errs, fmt.Errorf("records_cache_ttl: negative duration %s", p.RecordsCacheTTL))
+	}
+	switch p.CNAMEConflicts {
+	case "", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("cname_conflicts: unknown value %q", p.CNAMEConflicts))
+	}
+
+	return joinErrors(errs...)
+}
@@ -0,0 +1,159 @@
+package netlify
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// mxValue is the content of an MX record
+type mxValue struct {
+	Priority int
+	Target   string
+}
+
+// srvValue is the content of an SRV record
+type srvValue struct {
+	Priority int
+	Weight   int
+	Port     int
+	Target   string
+}
+
+// caaValue is the content of a CAA record
+type caaValue struct {
+	Flag  int
+	Tag   string
+	Value string
+}
+
+// parseUint16 parses field of a record as an integer between 0 and 65535
+func parseUint16(recType, field, s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, &ValidationError{Type: recType, Field: field, Reason: "not an integer"}
+	}
+	if n < 0 || n > 65535 {
+		return 0, &ValidationError{Type: recType, Field: field, Reason: "out of range"}
+	}
+	return n, nil
+}
+
+// parseMX parses the content of an MX record. The value is either the target
+// or the preference followed by the target, which then overrides the priority
+// of the record
+func parseMX(record libdns.Record) (mxValue, error) {
+	mx := mxValue{Priority: int(record.Priority)}
+	fields := strings.Fields(record.Value)
+	switch len(fields) {
+	case 1:
+		mx.Target = fields[0]
+	case 2:
+		priority, err := parseUint16("MX", "preference", fields[0])
+		if err != nil {
+			return mxValue{}, err
+		}
+		mx.Priority = priority
+		mx.Target = fields[1]
+	default:
+		return mxValue{}, &ValidationError{Type: "MX", Field: "target", Reason: "missing or malformed"}
+	}
+	if mx.Priority < 0 || mx.Priority > 65535 {
+		return mxValue{}, &ValidationError{Type: "MX", Field: "preference", Reason: "out of range"}
+	}
+	if err := validateName(mx.Target); err != nil {
+		return mxValue{}, &ValidationError{Type: "MX", Field: "target", Reason: err.Error()}
+	}
+	return mx, nil
+}
+
+// parseSRV parses the content of an SRV record. The value is "port target"
+// with the priority and weight of the record, as in libdns, "weight port
+// target" with the priority of the record, or "priority weight port target"
+func parseSRV(record libdns.Record) (srvValue, error) {
+	fields := strings.Fields(record.Value)
+	srv := srvValue{Priority: int(record.Priority)}
+	if record.Priority > 65535 {
+		return srvValue{}, &ValidationError{Type: "SRV", Field: "priority", Reason: "out of range"}
+	}
+	if len(fields) == 2 {
+		if record.Weight > 65535 {
+			return srvValue{}, &ValidationError{Type: "SRV", Field: "weight", Reason: "out of range"}
+		}
+		fields = append([]string{strconv.Itoa(int(record.Weight))}, fields...)
+	}
+	if len(fields) == 4 {
+		priority, err := parseUint16("SRV", "priority", fields[0])
+		if err != nil {
+			return srvValue{}, err
+		}
+		srv.Priority = priority
+		fields = fields[1:]
+	}
+	if len(fields) != 3 {
+		return srvValue{}, &ValidationError{Type: "SRV", Field: "value", Reason: `not in the "port target" form`}
+	}
+
+	var err error
+	if srv.Weight, err = parseUint16("SRV", "weight", fields[0]); err != nil {
+		return srvValue{}, err
+	}
+	if srv.Port, err = parseUint16("SRV", "port", fields[1]); err != nil {
+		return srvValue{}, err
+	}
+	srv.Target = fields[2]
+	if err := validateName(srv.Target); err != nil {
+		return srvValue{}, &ValidationError{Type: "SRV", Field: "target", Reason: err.Error()}
+	}
+	return srv, nil
+}
+
+// parseCAA parses the content of a CAA record, in the "flag tag value" form
+func parseCAA(record libdns.Record) (caaValue, error) {
+	fields := strings.SplitN(strings.TrimSpace(record.Value), " ", 3)
+	if len(fields) != 3 {
+		return caaValue{}, &ValidationError{Type: "CAA", Field: "value", Reason: `not in the "flag tag value" form`}
+	}
+
+	flag, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return caaValue{}, &ValidationError{Type: "CAA", Field: "flag", Reason: "not an integer"}
+	}
+	if flag < 0 || flag > 255 {
+		return caaValue{}, &ValidationError{Type: "CAA", Field: "flag", Reason: "out of range"}
+	}
+
+	tag := strings.ToLower(fields[1])
+	switch tag {
+	case "issue", "issuewild", "iodef":
+	default:
+		return caaValue{}, &ValidationError{Type: "CAA", Field: "tag", Reason: "not one of issue, issuewild or iodef"}
+	}
+
+	value := strings.TrimSpace(fields[2])
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+	if tag != "iodef" && strings.ContainsAny(value, " \t") {
+		return caaValue{}, &ValidationError{Type: "CAA", Field: "value", Reason: "contains whitespace"}
+	}
+	if tag == "iodef" && !strings.HasPrefix(value, "mailto:") &&
+		!strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+		return caaValue{}, &ValidationError{Type: "CAA", Field: "value", Reason: "iodef is not a mailto:, http: or https: URL"}
+	}
+
+	return caaValue{Flag: flag, Tag: tag, Value: value}, nil
+}
+
+// validateSRVName checks that name, the name of an SRV record, is in the
+// _service._proto.name form
+func validateSRVName(name string) error {
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	if len(labels) < 2 || len(labels[0]) < 2 || len(labels[1]) < 2 ||
+		!strings.HasPrefix(labels[0], "_") || !strings.HasPrefix(labels[1], "_") {
+		return &ValidationError{Type: "SRV", Field: "name", Reason: fmt.Sprintf("%q is not in the _service._proto.name form", name)}
+	}
+	return nil
+}
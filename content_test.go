@@ -0,0 +1,80 @@
+package netlify
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/libdns/libdns"
+)
+
+func TestValidateRecordMalformedContent(t *testing.T) {
+	tests := []struct {
+		name   string
+		record libdns.Record
+		field  string
+	}{
+		{"MX preference", libdns.Record{Type: "MX", Value: "ten mail.example.com"}, "preference"},
+		{"MX preference range", libdns.Record{Type: "MX", Value: "70000 mail.example.com"}, "preference"},
+		{"MX target missing", libdns.Record{Type: "MX", Value: ""}, "target"},
+		{"MX extra field", libdns.Record{Type: "MX", Value: "10 mail.example.com extra"}, "target"},
+		{"SRV port", libdns.Record{Type: "SRV", Name: "_sip._tcp", Value: "http sip.example.com"}, "port"},
+		{"SRV weight", libdns.Record{Type: "SRV", Name: "_sip._tcp", Value: "1 heavy 5060 sip.example.com"}, "weight"},
+		{"SRV priority", libdns.Record{Type: "SRV", Name: "_sip._tcp", Value: "x 1 5060 sip.example.com"}, "priority"},
+		{"SRV form", libdns.Record{Type: "SRV", Name: "_sip._tcp", Value: "sip.example.com"}, "value"},
+		{"SRV name", libdns.Record{Type: "SRV", Name: "sip", Value: "5060 sip.example.com"}, "name"},
+		{"CAA flag", libdns.Record{Type: "CAA", Value: `x issue "letsencrypt.org"`}, "flag"},
+		{"CAA flag range", libdns.Record{Type: "CAA", Value: `256 issue "letsencrypt.org"`}, "flag"},
+		{"CAA tag", libdns.Record{Type: "CAA", Value: `0 issuer "letsencrypt.org"`}, "tag"},
+		{"CAA form", libdns.Record{Type: "CAA", Value: "0 issue"}, "value"},
+		{"CAA iodef", libdns.Record{Type: "CAA", Value: `0 iodef "ftp://example.com"`}, "value"},
+	}
+	p := &Provider{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.validateRecord(tt.record, "example.com")
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("got error %v, want a *ValidationError", err)
+			}
+			if validationErr.Type != tt.record.Type || validationErr.Field != tt.field {
+				t.Errorf("got error %q on %s %s, want %s %s", err, validationErr.Type, validationErr.Field, tt.record.Type, tt.field)
+			}
+		})
+	}
+}
+
+func TestValidateRecordLowercaseType(t *testing.T) {
+	records := []libdns.Record{
+		{Type: "a", Name: "www", Value: "not-an-ip"},
+		{Type: "aaaa", Name: "www", Value: "192.0.2.1"},
+		{Type: "mx", Value: "ten mail.example.com"},
+		{Type: "srv", Name: "sip", Value: "5060 sip.example.com"},
+		{Type: "caa", Value: `x issue "letsencrypt.org"`},
+		{Type: "Alias", Name: "www", Value: "target.example.net"},
+	}
+	p := &Provider{}
+	for _, rec := range records {
+		err := p.validateRecord(rec, "example.com")
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("%s %q: got error %v, want a *ValidationError", rec.Type, rec.Value, err)
+		}
+	}
+}
+
+func TestValidateRecordWellFormedContent(t *testing.T) {
+	records := []libdns.Record{
+		{Type: "MX", Value: "mail.example.com", Priority: 10},
+		{Type: "MX", Value: "10 mail.example.com."},
+		{Type: "SRV", Name: "_sip._tcp", Value: "5060 sip.example.com", Priority: 10, Weight: 5},
+		{Type: "SRV", Name: "_sip._tcp", Value: "10 5 5060 sip.example.com"},
+		{Type: "CAA", Value: `0 issue "letsencrypt.org"`},
+		{Type: "CAA", Value: `128 iodef "mailto:security@example.com"`},
+	}
+	p := &Provider{}
+	for _, rec := range records {
+		if err := p.validateRecord(rec, "example.com"); err != nil {
+			t.Errorf("%s %q: got error %v", rec.Type, rec.Value, err)
+		}
+	}
+}
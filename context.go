@@ -0,0 +1,70 @@
+package netlify
+
+import "context"
+
+type dryRunKey struct{}
+
+type zoneIDKey struct{}
+
+type correlationIDKey struct{}
+
+type zoneNameKey struct{}
+
+type tokenKey struct{}
+
+// WithDryRun returns a copy of ctx which overrides the DryRun setting of the
+// provider for the calls made with it.
+func WithDryRun(ctx context.Context, dryRun bool) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, dryRun)
+}
+
+// dryRun reports whether mutations must be skipped for calls made with ctx
+func (p *Provider) dryRun(ctx context.Context) bool {
+	if dryRun, ok := ctx.Value(dryRunKey{}).(bool); ok {
+		return dryRun
+	}
+	return p.DryRun
+}
+
+// WithZoneID returns a copy of ctx which makes the calls made with it use
+// the Netlify zone with the given ID, without looking the zone up by name.
+func WithZoneID(ctx context.Context, zoneID string) context.Context {
+	return context.WithValue(ctx, zoneIDKey{}, zoneID)
+}
+
+// WithCorrelationID returns a copy of ctx making the requests sent for the
+// calls made with it carry id in their X-Correlation-Id header, to trace them
+// with Netlify support.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// WithToken returns a copy of ctx making the calls made with it authenticate
+// with token, overriding every other source of access token of the provider.
+func WithToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+// ensureContext returns ctx, or context.Background() if the caller passed a
+// nil context
+func (p *Provider) ensureContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		p.logger().Warn("nil context passed to the provider, using context.Background()")
+		return context.Background()
+	}
+	return ctx
+}
+
+// startOperation returns the context of a call to the provider on zone, if
+// any, bounded by OperationTimeout if set. The returned function must be
+// called once the operation is done
+func (p *Provider) startOperation(ctx context.Context, zone string) (context.Context, context.CancelFunc) {
+	ctx = p.ensureContext(ctx)
+	if zone != "" {
+		ctx = context.WithValue(ctx, zoneNameKey{}, zone)
+	}
+	if p.OperationTimeout > 0 {
+		return context.WithTimeout(ctx, p.OperationTimeout)
+	}
+	return context.WithCancel(ctx)
+}
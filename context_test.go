@@ -0,0 +1,114 @@
+package netlify
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/libdns/libdns"
+	"github.com/netlify/open-api/v2/go/models"
+)
+
+func TestWithDryRun(t *testing.T) {
+	api, p := newFakeAPI(t)
+	existing := api.addRecord("zone1", models.DNSRecord{Type: "TXT", Hostname: "old.example.com", Value: "old"})
+
+	ctx := context.Background()
+	dryCtx := WithDryRun(ctx, true)
+	record := libdns.Record{Type: "A", Name: "www", Value: "192.0.2.1"}
+
+	created, err := p.AppendRecords(dryCtx, "example.com", []libdns.Record{record})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 1 || created[0].Value != "192.0.2.1" {
+		t.Errorf("got %+v, want the record which would be created", created)
+	}
+	deleted, err := p.DeleteRecords(dryCtx, "example.com", []libdns.Record{{ID: existing.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 {
+		t.Errorf("got %+v, want the record which would be deleted", deleted)
+	}
+	for _, method := range []string{http.MethodPost, http.MethodPatch, http.MethodDelete} {
+		if n := api.countRequests(method, ""); n != 0 {
+			t.Errorf("sent %d %s requests in dry-run", n, method)
+		}
+	}
+
+	// calls without the override still change the zone
+	if _, err := p.AppendRecords(ctx, "example.com", []libdns.Record{record}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.DeleteRecords(ctx, "example.com", []libdns.Record{{ID: existing.ID}}); err != nil {
+		t.Fatal(err)
+	}
+	records := api.zoneRecords("zone1")
+	if len(records) != 1 || records[0].Type != "A" {
+		t.Errorf("zone has %+v, want only the created record", records)
+	}
+}
+
+func TestWithDryRunOverridesProvider(t *testing.T) {
+	api, p := newFakeAPI(t)
+	p.DryRun = true
+
+	ctx := WithDryRun(context.Background(), false)
+	if _, err := p.AppendRecords(ctx, "example.com", []libdns.Record{{Type: "A", Name: "www", Value: "192.0.2.1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(api.zoneRecords("zone1")); n != 1 {
+		t.Errorf("zone has %d records, want 1", n)
+	}
+}
+
+func TestNilContext(t *testing.T) {
+	api, p := newFakeAPI(t)
+	logger := &testLogger{}
+	p.Logger = logger
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: "192.0.2.1"})
+
+	var ctx context.Context
+	records, err := p.GetRecords(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Errorf("got %d records, want 1", len(records))
+	}
+	if _, err := p.AppendRecords(ctx, "example.com", []libdns.Record{{Type: "A", Name: "api", Value: "192.0.2.2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(logger.logged("WARN", "nil context")) != 2 {
+		t.Errorf("logged %q, want a warning per call", logger.messages)
+	}
+}
+
+func TestWithZoneID(t *testing.T) {
+	api, p := newFakeAPI(t)
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: "192.0.2.1"})
+
+	ctx := WithZoneID(context.Background(), "zone1")
+	created, err := p.AppendRecords(ctx, "example.com.", []libdns.Record{{Type: "A", Name: "api", Value: "192.0.2.2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := p.GetRecords(ctx, "example.com.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.DeleteRecords(ctx, "example.com.", created); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 || records[1].Name != "api" {
+		t.Errorf("got %+v, want both records", records)
+	}
+	if n := api.countRequests(http.MethodGet, "/dns_zones"); n != 0 {
+		t.Errorf("sent %d zone lookups, want none", n)
+	}
+	if n := len(api.zoneRecords("zone1")); n != 1 {
+		t.Errorf("zone has %d records, want 1", n)
+	}
+}
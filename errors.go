@@ -0,0 +1,271 @@
+package netlify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// ErrRecordNotFound is returned when no record in the zone matches the name
+// and type of a record looked up without its ID.
+var ErrRecordNotFound = errors.New("record not found")
+
+// ErrZoneNotFound is returned when Netlify has no DNS zone with the name
+// passed to the provider.
+var ErrZoneNotFound = errors.New("zone not found")
+
+// ErrMissingRecordID is returned when Netlify answers a record creation with
+// a record that has no ID, which prevents managing it afterwards.
+var ErrMissingRecordID = errors.New("created record has no ID")
+
+// ErrZoneInactive is returned when records are changed in a zone which is not
+// active on Netlify.
+var ErrZoneInactive = errors.New("zone is not active")
+
+// ErrCNAMEConflict is returned when creating a record would make a CNAME
+// record coexist with another record with the same name.
+var ErrCNAMEConflict = errors.New("CNAME record conflict")
+
+// ErrManagedRecord is returned when creating a NETLIFY or NETLIFYv6 record,
+// which only Netlify manages, unless AllowManagedRecordChanges is set.
+var ErrManagedRecord = errors.New("record managed by Netlify")
+
+// ErrApexNSChange is returned when updating or deleting an NS record at the
+// apex of the zone, which would break its delegation, unless
+// AllowDangerousNSChanges is set.
+var ErrApexNSChange = errors.New("refusing to change apex NS record")
+
+// ErrCircuitOpen is returned without sending the request after too many
+// consecutive failures, until the circuit breaker cool-down has elapsed.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// ValidationError is returned when the content of a record is rejected
+// before being sent to Netlify. Field names the part of the content which
+// is invalid.
+type ValidationError struct {
+	Type   string
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s %s", e.Type, e.Field, e.Reason)
+}
+
+// NetworkError is returned when a request to Netlify's API couldn't complete,
+// for example because the connection was refused or timed out.
+type NetworkError struct {
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("netlify request failed: %v", e.Err)
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// ResponseTooLargeError is returned when the body of a response from
+// Netlify's API is larger than the MaxResponseSize of the provider.
+type ResponseTooLargeError struct {
+	Limit int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response body larger than %d bytes", e.Limit)
+}
+
+// APIError is returned when Netlify's API answers with an error status. The
+// error payload sent by Netlify is decoded into Code, Message and Errors when
+// possible.
+type APIError struct {
+	StatusCode int    `json:"-"`
+	Body       string `json:"-"`
+
+	// RequestID is the ID Netlify gave to the request, to
+	// share with Netlify support
+	RequestID string `json:"-"`
+
+	Code    int                    `json:"code,omitempty"`
+	Message string                 `json:"message,omitempty"`
+	Errors  map[string]interface{} `json:"errors,omitempty"`
+
+	// RetryAfter is how long to wait before trying again, as
+	// told by Netlify when rate limiting (HTTP 429)
+	RetryAfter time.Duration `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	var msg string
+	switch {
+	case e.Message == "":
+		msg = fmt.Sprintf("got error status: HTTP %d: %+v", e.StatusCode, e.Body)
+	case len(e.Errors) > 0:
+		msg = fmt.Sprintf("got error status: HTTP %d: %s: %v", e.StatusCode, e.Message, e.Errors)
+	default:
+		msg = fmt.Sprintf("got error status: HTTP %d: %s", e.StatusCode, e.Message)
+	}
+	if e.RequestID != "" {
+		msg += " (request ID " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// newAPIError returns the error for a response with the given status and
+// body, decoding Netlify's error payload if there is one
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RequestID:  resp.Header.Get(requestIDHeader),
+	}
+	json.Unmarshal(body, apiErr)
+	return apiErr
+}
+
+// CredentialsProblem is the kind of problem found by VerifyCredentials.
+type CredentialsProblem int
+
+const (
+	// InvalidToken means Netlify rejected the access token.
+	InvalidToken CredentialsProblem = iota + 1
+	// InsufficientScope means the access token isn't allowed to manage
+	// DNS zones.
+	InsufficientScope
+	// NetworkFailure means Netlify's API couldn't be reached.
+	NetworkFailure
+)
+
+func (c CredentialsProblem) String() string {
+	switch c {
+	case InvalidToken:
+		return "invalid token"
+	case InsufficientScope:
+		return "insufficient scope"
+	case NetworkFailure:
+		return "network failure"
+	}
+	return fmt.Sprintf("CredentialsProblem(%d)", int(c))
+}
+
+// CredentialsError is returned by VerifyCredentials when the credentials of
+// the provider can't be used. Err is the error of the request.
+type CredentialsError struct {
+	Problem CredentialsProblem
+	Err     error
+}
+
+func (e *CredentialsError) Error() string {
+	return fmt.Sprintf("verifying credentials: %s: %v", e.Problem, e.Err)
+}
+
+func (e *CredentialsError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable reports whether err is a temporary failure, such as a network
+// error, rate limiting or a server error, after which the operation may
+// succeed if tried again.
+func IsRetryable(err error) bool {
+	return retryable(err)
+}
+
+// IsNotFound reports whether err is caused by a missing zone or record,
+// including when Netlify's API answers 404 Not Found.
+func IsNotFound(err error) bool {
+	if errors.Is(err, ErrRecordNotFound) || errors.Is(err, ErrZoneNotFound) {
+		return true
+	}
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// IsAuthError reports whether err is caused by Netlify rejecting the access
+// token, because it is invalid or not allowed to do the operation.
+func IsAuthError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden
+}
+
+// batchError wraps the error err about the record at index i of a batch
+func batchError(i int, rec libdns.Record, err error) error {
+	return fmt.Errorf("record %d (%s %s): %w", i, rec.Type, rec.Name, err)
+}
+
+// causedError is the error err of a request which ended because of cause,
+// such as a context error stopping the retries. Both err and cause can be
+// found with errors.Is and errors.As
+type causedError struct {
+	msg   string
+	cause error
+	err   error
+}
+
+func (e *causedError) Error() string {
+	if e.msg == "" {
+		return e.cause.Error() + ": " + e.err.Error()
+	}
+	return e.msg + ": " + e.cause.Error() + ": " + e.err.Error()
+}
+
+func (e *causedError) Unwrap() error {
+	return e.err
+}
+
+func (e *causedError) Is(target error) bool {
+	return errors.Is(e.cause, target)
+}
+
+// joinedErrors are errors reported together, one per line, as returned by
+// joinErrors
+type joinedErrors []error
+
+func (e joinedErrors) Error() string {
+	lines := make([]string, 0, len(e))
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (e joinedErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e joinedErrors) As(target any) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinErrors returns an error wrapping the non-nil errors, or nil if there
+// is none. errors.Join does the same from Go 1.20
+func joinErrors(errs ...error) error {
+	var joined joinedErrors
+	for _, err := range errs {
+		if err != nil {
+			joined = append(joined, err)
+		}
+	}
+	if len(joined) == 0 {
+		return nil
+	}
+	return joined
+}
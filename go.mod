@@ -1,10 +1,12 @@
 module github.com/CL0Pinette/libdns-netlify
 
-go 1.17
+go 1.18
 
 require (
-	github.com/libdns/libdns v0.2.1
+	github.com/joho/godotenv v1.4.0
+	github.com/libdns/libdns v0.2.2
 	github.com/netlify/open-api/v2 v2.9.0
+	golang.org/x/net v0.0.0-20210405180319-a5a99cb37ef4
 )
 
 require (
@@ -22,12 +24,10 @@ require (
 	github.com/go-openapi/swag v0.19.12 // indirect
 	github.com/go-openapi/validate v0.20.0 // indirect
 	github.com/go-stack/stack v1.8.0 // indirect
-	github.com/joho/godotenv v1.4.0 //indirect
 	github.com/josharian/intern v1.0.0 // indirect
 	github.com/mailru/easyjson v0.7.6 // indirect
 	github.com/mitchellh/mapstructure v1.4.0 // indirect
 	go.mongodb.org/mongo-driver v1.4.4 // indirect
-	golang.org/x/net v0.0.0-20210405180319-a5a99cb37ef4 // indirect
 	golang.org/x/text v0.3.4 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
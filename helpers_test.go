@@ -0,0 +1,322 @@
+package netlify
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/netlify/open-api/v2/go/models"
+)
+
+// testToken is the access token of the providers talking to a fakeAPI
+const testToken = "nfp_testtoken1234567890"
+
+// fakeAPI is an in-memory implementation of the DNS endpoints of Netlify's
+// API, serving the zones and records it holds
+type fakeAPI struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	zones    []netlifyZone
+	records  map[string][]netlifyDNSRecord
+	nextID   int
+	requests []string
+
+	// intercept, if set, is called before the request is served; it
+	// returns true if it answered the request itself
+	intercept func(w http.ResponseWriter, r *http.Request) bool
+}
+
+// newFakeAPI starts a fakeAPI holding the zone example.com, with ID zone1,
+// and returns it with a provider using it
+func newFakeAPI(t *testing.T) (*fakeAPI, *Provider) {
+	t.Helper()
+
+	api := &fakeAPI{records: make(map[string][]netlifyDNSRecord)}
+	api.addZone("zone1", "example.com")
+	api.Server = httptest.NewServer(http.HandlerFunc(api.serveHTTP))
+	t.Cleanup(api.Close)
+
+	return api, &Provider{PersonnalAccessToken: testToken, BaseURL: api.URL}
+}
+
+// addZone adds a zone to the API
+func (api *fakeAPI) addZone(id, name string) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	api.zones = append(api.zones, netlifyZone{DNSZone: &models.DNSZone{ID: id, Name: name}})
+}
+
+// addRecord adds a record to a zone of the API, giving it an ID if it has
+// none, and returns it
+func (api *fakeAPI) addRecord(zoneID string, rec models.DNSRecord) netlifyDNSRecord {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	return api.store(zoneID, netlifyDNSRecord{DNSRecord: &rec})
+}
+
+// store adds rec to the zone; api.mu must be held
+func (api *fakeAPI) store(zoneID string, rec netlifyDNSRecord) netlifyDNSRecord {
+	if rec.ID == "" {
+		api.nextID++
+		rec.ID = fmt.Sprintf("rec%d", api.nextID)
+	}
+	rec.DNSZoneID = zoneID
+	api.records[zoneID] = append(api.records[zoneID], rec)
+	return rec
+}
+
+// zoneRecords returns the records of a zone of the API
+func (api *fakeAPI) zoneRecords(zoneID string) []netlifyDNSRecord {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	return append([]netlifyDNSRecord(nil), api.records[zoneID]...)
+}
+
+// requestLog returns the requests served so far, as "METHOD path"
+func (api *fakeAPI) requestLog() []string {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	return append([]string(nil), api.requests...)
+}
+
+// countRequests returns the number of requests served so far with the given
+// method and a path ending with suffix
+func (api *fakeAPI) countRequests(method, suffix string) int {
+	n := 0
+	for _, req := range api.requestLog() {
+		if strings.HasPrefix(req, method+" ") && strings.HasSuffix(req, suffix) {
+			n++
+		}
+	}
+	return n
+}
+
+func (api *fakeAPI) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	api.requests = append(api.requests, r.Method+" "+r.URL.Path)
+	intercept := api.intercept
+	api.mu.Unlock()
+
+	if intercept != nil && intercept(w, r) {
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+testToken {
+		http.Error(w, `{"code":401,"message":"Access Denied"}`, http.StatusUnauthorized)
+		return
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "dns_zones" && r.Method == http.MethodGet:
+		name := r.URL.Query().Get("name")
+		zones := []netlifyZone{}
+		for _, zone := range api.zones {
+			if name == "" || zone.Name == name {
+				zones = append(zones, zone)
+			}
+		}
+		writeJSON(w, http.StatusOK, zones)
+	case len(parts) == 2 && parts[0] == "dns_zones" && r.Method == http.MethodGet:
+		for _, zone := range api.zones {
+			if zone.ID == parts[1] {
+				writeJSON(w, http.StatusOK, zone)
+				return
+			}
+		}
+		http.Error(w, `{"code":404,"message":"Not Found"}`, http.StatusNotFound)
+	case len(parts) == 3 && parts[2] == "dns_records" && r.Method == http.MethodGet:
+		records := api.records[parts[1]]
+		if records == nil {
+			records = []netlifyDNSRecord{}
+		}
+		writeJSON(w, http.StatusOK, pageOf(w, r, records))
+	case len(parts) == 3 && parts[2] == "dns_records" && r.Method == http.MethodPost:
+		var rec netlifyDNSRecord
+		if err := json.NewDecoder(r.Body).Decode(&rec); err != nil || rec.DNSRecord == nil {
+			http.Error(w, `{"code":422,"message":"invalid record"}`, http.StatusUnprocessableEntity)
+			return
+		}
+		rec.ID = ""
+		writeJSON(w, http.StatusCreated, api.store(parts[1], rec))
+	case len(parts) == 4 && parts[2] == "dns_records":
+		records := api.records[parts[1]]
+		i := 0
+		for i < len(records) && records[i].ID != parts[3] {
+			i++
+		}
+		if i == len(records) {
+			http.Error(w, `{"code":404,"message":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, records[i])
+		case http.MethodPatch:
+			var patch netlifyDNSRecord
+			if err := json.NewDecoder(r.Body).Decode(&patch); err != nil || patch.DNSRecord == nil {
+				http.Error(w, `{"code":422,"message":"invalid record"}`, http.StatusUnprocessableEntity)
+				return
+			}
+			records[i] = patchRecord(records[i], patch)
+			writeJSON(w, http.StatusOK, records[i])
+		case http.MethodDelete:
+			api.records[parts[1]] = append(records[:i:i], records[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	default:
+		http.Error(w, `{"code":404,"message":"Not Found"}`, http.StatusNotFound)
+	}
+}
+
+// pageOf returns the page of records requested by the page and per_page
+// parameters of r, linking to the next page in the Link header if there is
+// one. All the records are returned if r has no paging parameters
+func pageOf(w http.ResponseWriter, r *http.Request, records []netlifyDNSRecord) []netlifyDNSRecord {
+	qs := r.URL.Query()
+	page, err := strconv.Atoi(qs.Get("page"))
+	if err != nil || page < 1 {
+		return records
+	}
+	perPage, err := strconv.Atoi(qs.Get("per_page"))
+	if err != nil || perPage < 1 {
+		return records
+	}
+
+	start := (page - 1) * perPage
+	if start >= len(records) {
+		return []netlifyDNSRecord{}
+	}
+	end := start + perPage
+	if end < len(records) {
+		qs.Set("page", strconv.Itoa(page+1))
+		next := url.URL{Path: r.URL.Path, RawQuery: qs.Encode()}
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+	} else {
+		end = len(records)
+	}
+	return records[start:end]
+}
+
+// patchRecord returns rec with the non-empty fields of patch
+func patchRecord(rec, patch netlifyDNSRecord) netlifyDNSRecord {
+	updated := *rec.DNSRecord
+	if patch.Type != "" {
+		updated.Type = patch.Type
+	}
+	if patch.Hostname != "" {
+		updated.Hostname = patch.Hostname
+	}
+	if patch.Value != "" {
+		updated.Value = patch.Value
+	}
+	if patch.TTL != 0 {
+		updated.TTL = patch.TTL
+	}
+	if patch.Priority != 0 {
+		updated.Priority = patch.Priority
+	}
+	rec.DNSRecord = &updated
+	if patch.Weight != 0 {
+		rec.Weight = patch.Weight
+	}
+	if patch.Port != 0 {
+		rec.Port = patch.Port
+	}
+	return rec
+}
+
+// writeJSON sends v encoded in JSON with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// fakeClock is a Clock whose time only moves when waited for: After records
+// the delay, advances the time by it and fires at once
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waits = append(c.waits, d)
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// advance moves the time forward by d without recording a wait
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// waited returns the delays waited for so far
+func (c *fakeClock) waited() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.waits...)
+}
+
+// testLogger is a Logger keeping the messages it receives, with their
+// arguments
+type testLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *testLogger) Debug(msg string, args ...any) { l.log("DEBUG", msg, args) }
+func (l *testLogger) Warn(msg string, args ...any)  { l.log("WARN", msg, args) }
+
+func (l *testLogger) log(level, msg string, args []any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, fmt.Sprint(level, " ", msg, " ", args))
+}
+
+// logged returns the messages of the given level containing substr
+func (l *testLogger) logged(level, substr string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var found []string
+	for _, msg := range l.messages {
+		if strings.HasPrefix(msg, level+" ") && strings.Contains(msg, substr) {
+			found = append(found, msg)
+		}
+	}
+	return found
+}
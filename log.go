@@ -0,0 +1,23 @@
+package netlify
+
+// Logger receives the warnings and debug messages of the provider, with
+// alternating keys and values as args. A *slog.Logger can be used as Logger.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Warn(msg string, args ...any)
+}
+
+// discardLogger is a Logger discarding everything
+type discardLogger struct{}
+
+func (discardLogger) Debug(msg string, args ...any) {}
+func (discardLogger) Warn(msg string, args ...any)  {}
+
+// logger returns the Logger of the provider, defaulting to a logger which
+// discards everything
+func (p *Provider) logger() Logger {
+	if p.Logger != nil {
+		return p.Logger
+	}
+	return discardLogger{}
+}
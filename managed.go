@@ -0,0 +1,54 @@
+package netlify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// IsManagedRecord reports whether the record is one of the NETLIFY and
+// NETLIFYv6 records Netlify creates and keeps up to date itself to point the
+// names of a zone at its sites. GetRecords returns them with these types. The
+// provider doesn't create, change or delete them unless
+// AllowManagedRecordChanges is set.
+func IsManagedRecord(record libdns.Record) bool {
+	return strings.EqualFold(record.Type, "NETLIFY") || strings.EqualFold(record.Type, "NETLIFYv6")
+}
+
+// managed reports whether Netlify manages the record, from its type or its
+// managed flag
+func (r netlifyDNSRecord) managed() bool {
+	return r.Managed || IsManagedRecord(libdns.Record{Type: r.Type})
+}
+
+// unprotected returns the records which aren't managed by Netlify, or all
+// of them if AllowManagedRecordChanges is set
+func (p *Provider) unprotected(records []netlifyDNSRecord) []netlifyDNSRecord {
+	if p.AllowManagedRecordChanges {
+		return records
+	}
+	var kept []netlifyDNSRecord
+	for _, rec := range records {
+		if rec.managed() {
+			p.logger().Debug("skipping record managed by Netlify",
+				"name", rec.Hostname, "type", rec.Type, "id", rec.ID)
+			continue
+		}
+		kept = append(kept, rec)
+	}
+	return kept
+}
+
+// checkNSChange returns an error wrapping ErrApexNSChange if rec is an NS
+// record at the apex of the zone, which delegates the zone, unless
+// AllowDangerousNSChanges is set
+func (p *Provider) checkNSChange(zoneInfo netlifyZone, rec netlifyDNSRecord) error {
+	if p.AllowDangerousNSChanges || !strings.EqualFold(rec.Type, "NS") {
+		return nil
+	}
+	if normalizeName(rec.Hostname, "") != normalizeName("", zoneInfo.Name) {
+		return nil
+	}
+	return fmt.Errorf("NS record %s: %w", rec.Hostname, ErrApexNSChange)
+}
@@ -1,6 +1,9 @@
 package netlify
 
 import (
+	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/libdns/libdns"
@@ -9,36 +12,237 @@ import (
 
 type netlifyZone struct {
 	*models.DNSZone
+
+	// Status is not part of the generated model but is
+	// returned for zones which are not active yet
+	Status string `json:"status,omitempty"`
+
+	// inParent is set for a zone resolved to the Netlify zone
+	// of a parent domain, which also holds records outside it
+	inParent bool
+}
+
+// active reports whether records of the zone can be changed
+func (z netlifyZone) active() bool {
+	return z.Status == "" || z.Status == "active"
+}
+
+// record returns the record r of the zone as sent to Netlify, with its
+// absolute hostname
+func (z netlifyZone) record(r libdns.Record) netlifyDNSRecord {
+	rec := netlifyRecord(r)
+	rec.Hostname = normalizeName(r.Name, z.Name)
+	rec.DNSZoneID = z.ID
+	return rec
 }
 
 type netlifyDNSRecord struct {
 	*models.DNSRecord
+
+	// Weight and Port are the fields of SRV records, which
+	// models.DNSRecord lacks
+	Weight int64 `json:"weight,omitempty"`
+	Port   int64 `json:"port,omitempty"`
+}
+
+// RecordConverter converts the records of a type the provider doesn't
+// handle natively between libdns and Netlify's API.
+type RecordConverter interface {
+	// ToNetlify converts a libdns record to the record sent to Netlify
+	ToNetlify(record libdns.Record) models.DNSRecord
+	// FromNetlify converts a record returned by Netlify to a libdns
+	// record with a name relative to zone
+	FromNetlify(record models.DNSRecord, zone string) libdns.Record
+}
+
+var (
+	converters   = make(map[string]RecordConverter)
+	convertersMu sync.RWMutex
+)
+
+// RegisterRecordType registers the converter used for the records of the
+// given type, replacing the default conversion.
+func RegisterRecordType(recType string, converter RecordConverter) {
+	convertersMu.Lock()
+	defer convertersMu.Unlock()
+
+	converters[strings.ToUpper(recType)] = converter
+}
+
+// converterFor returns the converter registered for the type, if any
+func converterFor(recType string) RecordConverter {
+	convertersMu.RLock()
+	defer convertersMu.RUnlock()
+
+	return converters[strings.ToUpper(recType)]
 }
 
 func (r netlifyDNSRecord) libdnsRecord(zone string) libdns.Record {
-	return libdns.Record{
-		Type:  r.Type,
-		Name:  libdns.RelativeName(r.Hostname, zone),
-		Value: r.Value,
-		TTL:   time.Duration(r.TTL) * time.Second,
-		ID:    r.ID,
+	if converter := converterFor(r.Type); converter != nil {
+		return converter.FromNetlify(*r.DNSRecord, zone)
+	}
+	rec := libdns.Record{
+		Type:     r.Type,
+		Name:     relativeName(r.Hostname, zone),
+		Value:    normalizeValue(r.Type, r.Value),
+		TTL:      ttlDuration(r.TTL),
+		Priority: uint(r.Priority),
+		ID:       r.ID,
+	}
+	switch {
+	case strings.EqualFold(r.Type, "SRV") && r.Port != 0:
+		// libdns puts the port of SRV records before the target
+		rec.Value = fmt.Sprintf("%d %s", r.Port, rec.Value)
+		rec.Weight = uint(r.Weight)
+	case strings.EqualFold(r.Type, "CAA") && r.Tag != "":
+		rec.Value = fmt.Sprintf("%d %s %q", r.Flag, r.Tag, r.Value)
 	}
+	return rec
 }
 
 func netlifyRecord(r libdns.Record) netlifyDNSRecord {
-	return netlifyDNSRecord{
-		&models.DNSRecord{
-			ID:       r.ID,
-			Type:     r.Type,
-			Hostname: r.Name,
-			Value:    r.Value,
-			TTL:      int64(r.TTL.Seconds()),
-			Priority: int64(r.Priority),
-		},
+	if converter := converterFor(r.Type); converter != nil {
+		rec := converter.ToNetlify(r)
+		return netlifyDNSRecord{DNSRecord: &rec}
+	}
+	rec := &models.DNSRecord{
+		ID:       r.ID,
+		Type:     r.Type,
+		Hostname: toASCIIName(r.Name),
+		Value:    r.Value,
+		TTL:      ttlSeconds(r.TTL),
+		Priority: int64(r.Priority),
+	}
+	result := netlifyDNSRecord{DNSRecord: rec}
+	switch strings.ToUpper(r.Type) {
+	case "MX":
+		// the preference may be given in the value, as in zone files,
+		// but Netlify expects it in the priority
+		if mx, err := parseMX(r); err == nil {
+			rec.Priority = int64(mx.Priority)
+			rec.Value = mx.Target
+		}
+	case "TXT", "SPF":
+		// long values must be split in strings of up to 255 bytes
+		if value := normalizeValue(r.Type, r.Value); len(value) > maxTXTString {
+			rec.Value = splitTXT(value)
+		}
+	case "CAA":
+		// Netlify expects the flag and tag in their own fields
+		if caa, err := parseCAA(r); err == nil {
+			rec.Flag = int64(caa.Flag)
+			rec.Tag = caa.Tag
+			rec.Value = caa.Value
+		}
+	case "SRV":
+		// Netlify expects the target alone in the value
+		if srv, err := parseSRV(r); err == nil {
+			rec.Priority = int64(srv.Priority)
+			result.Weight = int64(srv.Weight)
+			result.Port = int64(srv.Port)
+			rec.Value = srv.Target
+		}
 	}
+	return result
 }
 
-type netlifyDNSDeleteError struct {
-	Code    int    `json:"code,omitempty"`
-	Message string `json:"message,omitempty"`
+// TypedRecord converts the flat libdns record r to a typed Record, keeping
+// the fields specific to its type: the priority of MX records, and the
+// priority, weight and port of SRV records. The ID of r is dropped, as typed
+// records have none. It returns a *ValidationError if the value of r isn't
+// valid for its type.
+func TypedRecord(r libdns.Record) (Record, error) {
+	rr := RR{Name: r.Name, TTL: r.TTL, Type: r.Type, Data: r.Value}
+	switch strings.ToUpper(r.Type) {
+	case "MX":
+		mx, err := parseMX(r)
+		if err != nil {
+			return nil, err
+		}
+		rr.Data = fmt.Sprintf("%d %s", mx.Priority, mx.Target)
+	case "SRV":
+		srv, err := parseSRV(r)
+		if err != nil {
+			return nil, err
+		}
+		rr.Data = fmt.Sprintf("%d %d %d %s", srv.Priority, srv.Weight, srv.Port, srv.Target)
+	case "TXT", "SPF":
+		rr.Data = normalizeValue(r.Type, r.Value)
+	}
+	return rr.Parse()
+}
+
+// FlatRecord converts the typed record r to a flat libdns record, as taken
+// and returned by the methods of the provider implementing the libdns
+// interfaces. The priority of MX records and the priority and weight of SRV
+// records go in their own fields, as netlifyRecord expects them.
+func FlatRecord(r Record) libdns.Record {
+	rr := r.RR()
+	rec := libdns.Record{Type: rr.Type, Name: rr.Name, Value: rr.Data, TTL: rr.TTL}
+	switch strings.ToUpper(rr.Type) {
+	case "MX":
+		if mx, err := parseMX(rec); err == nil {
+			rec.Priority = uint(mx.Priority)
+			rec.Value = mx.Target
+		}
+	case "SRV":
+		if srv, err := parseSRV(rec); err == nil {
+			rec.Priority = uint(srv.Priority)
+			rec.Weight = uint(srv.Weight)
+			rec.Value = fmt.Sprintf("%d %s", srv.Port, srv.Target)
+		}
+	}
+	return rec
+}
+
+// ttlSeconds converts a libdns TTL to the whole seconds expected by Netlify,
+// rounding to the nearest second. A positive TTL below half a second becomes
+// one second rather than zero, which would mean no TTL
+func ttlSeconds(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		return 0
+	}
+	secs := int64(ttl.Round(time.Second) / time.Second)
+	if secs == 0 {
+		secs = 1
+	}
+	return secs
+}
+
+// ttlDuration converts a TTL in seconds returned by Netlify to a libdns TTL
+func ttlDuration(secs int64) time.Duration {
+	if secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// sameValue reports whether the record r has the value of the Netlify record
+// rec, once both are in canonical form
+func sameValue(rec netlifyDNSRecord, r libdns.Record) bool {
+	want := netlifyRecord(r)
+	return normalizeValue(rec.Type, rec.Value) == normalizeValue(r.Type, want.Value) &&
+		rec.Port == want.Port && rec.Flag == want.Flag && strings.EqualFold(rec.Tag, want.Tag)
+}
+
+// unchanged reports whether updating current with r would change nothing.
+// Empty fields of r are left untouched by an update
+func unchanged(current netlifyDNSRecord, r libdns.Record) bool {
+	if r.Type != "" && !strings.EqualFold(current.Type, r.Type) {
+		return false
+	}
+	if r.Value != "" && !sameValue(current, r) {
+		return false
+	}
+	if r.TTL != 0 && current.TTL != ttlSeconds(r.TTL) {
+		return false
+	}
+	want := netlifyRecord(r)
+	if want.Priority != 0 && current.Priority != want.Priority {
+		return false
+	}
+	if want.Weight != 0 && current.Weight != want.Weight {
+		return false
+	}
+	return true
 }
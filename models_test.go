@@ -0,0 +1,289 @@
+package netlify
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/netlify/open-api/v2/go/models"
+)
+
+func TestAppendRecordsIDNZone(t *testing.T) {
+	api, p := newFakeAPI(t)
+	api.addZone("zone2", "xn--bcher-kva.example")
+
+	ctx := context.Background()
+	created, err := p.AppendRecords(ctx, "bücher.example", []libdns.Record{
+		{Type: "A", Name: "wörter", Value: "192.0.2.1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 1 || created[0].Name != "wörter" {
+		t.Fatalf("created %+v, want a record named wörter", created)
+	}
+
+	stored := api.zoneRecords("zone2")
+	if len(stored) != 1 || stored[0].Hostname != "xn--wrter-jua.xn--bcher-kva.example" {
+		t.Fatalf("zone has %+v, want a record with a punycode hostname", stored)
+	}
+
+	records, err := p.GetRecords(ctx, "bücher.example.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Name != "wörter" {
+		t.Errorf("got %+v, want a record named wörter", records)
+	}
+}
+
+// uriConverter converts URI records, whose libdns value is "priority weight
+// target", to Netlify records with the priority in its own field and the
+// weight and target in the value
+type uriConverter struct{}
+
+func (uriConverter) ToNetlify(record libdns.Record) models.DNSRecord {
+	var priority int64
+	var rest string
+	fmt.Sscanf(record.Value, "%d", &priority)
+	if i := strings.Index(record.Value, " "); i >= 0 {
+		rest = record.Value[i+1:]
+	}
+	return models.DNSRecord{
+		Type:     "URI",
+		Hostname: record.Name,
+		Value:    rest,
+		Priority: priority,
+		TTL:      ttlSeconds(record.TTL),
+	}
+}
+
+func (uriConverter) FromNetlify(record models.DNSRecord, zone string) libdns.Record {
+	return libdns.Record{
+		ID:    record.ID,
+		Type:  "URI",
+		Name:  relativeName(record.Hostname, zone),
+		Value: fmt.Sprintf("%d %s", record.Priority, record.Value),
+		TTL:   ttlDuration(record.TTL),
+	}
+}
+
+func TestRegisterRecordType(t *testing.T) {
+	RegisterRecordType("uri", uriConverter{})
+	api, p := newFakeAPI(t)
+
+	ctx := context.Background()
+	record := libdns.Record{Type: "URI", Name: "_http._tcp", Value: `10 1 "https://example.com/"`, TTL: time.Hour}
+	created, err := p.AppendRecords(ctx, "example.com", []libdns.Record{record})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := api.zoneRecords("zone1")
+	if len(stored) != 1 || stored[0].Priority != 10 || stored[0].Value != `1 "https://example.com/"` {
+		t.Fatalf("zone has %+v, want the record converted by the registered converter", stored)
+	}
+	if stored[0].Hostname != "_http._tcp.example.com" {
+		t.Errorf("got hostname %q, want it absolute", stored[0].Hostname)
+	}
+
+	records, err := p.GetRecords(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	record.ID = stored[0].ID
+	if len(created) != 1 || created[0] != record {
+		t.Errorf("created %+v, want %+v", created, record)
+	}
+	if len(records) != 1 || records[0] != record {
+		t.Errorf("got %+v, want %+v", records, record)
+	}
+}
+
+func TestTypedRecords(t *testing.T) {
+	api, p := newFakeAPI(t)
+
+	ctx := context.Background()
+	records := []Record{
+		Address{Name: "www", TTL: time.Hour, IP: netip.MustParseAddr("192.0.2.1")},
+		Address{Name: "www", TTL: time.Hour, IP: netip.MustParseAddr("2001:db8::1")},
+		TXT{Name: "@", TTL: time.Hour, Text: "v=spf1 -all"},
+		SRV{Service: "sip", Transport: "tcp", TTL: time.Hour, Priority: 10, Weight: 5, Port: 5060, Target: "sip.example.com"},
+		RR{Name: "", TTL: time.Hour, Type: "MX", Data: "20 mail.example.com"},
+	}
+	created, err := p.AppendTypedRecords(ctx, "example.com", records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored := api.zoneRecords("zone1")
+	if len(stored) != len(records) {
+		t.Fatalf("zone has %d records, want %d", len(stored), len(records))
+	}
+	if srv := stored[3]; srv.Priority != 10 || srv.Weight != 5 || srv.Port != 5060 || srv.Value != "sip.example.com" {
+		t.Errorf("sent SRV record %+v, want its fields in their own fields", srv)
+	}
+	if mx := stored[4]; mx.Priority != 20 || mx.Value != "mail.example.com" {
+		t.Errorf("sent MX record %+v, want the preference in the priority", mx)
+	}
+
+	got, err := p.GetTypedRecords(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Record{
+		Address{Name: "www", TTL: time.Hour, IP: netip.MustParseAddr("192.0.2.1")},
+		Address{Name: "www", TTL: time.Hour, IP: netip.MustParseAddr("2001:db8::1")},
+		TXT{Name: "", TTL: time.Hour, Text: "v=spf1 -all"},
+		SRV{Service: "sip", Transport: "tcp", TTL: time.Hour, Priority: 10, Weight: 5, Port: 5060, Target: "sip.example.com"},
+		RR{Name: "", TTL: time.Hour, Type: "MX", Data: "20 mail.example.com"},
+	}
+	if !reflect.DeepEqual(created, want) {
+		t.Errorf("created %+v, want %+v", created, want)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestTypedRecordConversion(t *testing.T) {
+	tests := []struct {
+		flat  libdns.Record
+		typed Record
+	}{
+		{
+			libdns.Record{Type: "A", Name: "www", Value: "192.0.2.1", TTL: time.Minute},
+			Address{Name: "www", TTL: time.Minute, IP: netip.MustParseAddr("192.0.2.1")},
+		},
+		{
+			libdns.Record{Type: "TXT", Name: "_acme-challenge", Value: "token"},
+			TXT{Name: "_acme-challenge", Text: "token"},
+		},
+		{
+			libdns.Record{Type: "SRV", Name: "_xmpp._tcp.chat", Value: "5222 xmpp.example.com", Priority: 1, Weight: 2},
+			SRV{Service: "xmpp", Transport: "tcp", Name: "chat", Priority: 1, Weight: 2, Port: 5222, Target: "xmpp.example.com"},
+		},
+		{
+			libdns.Record{Type: "MX", Name: "", Value: "mail.example.com", Priority: 10},
+			RR{Type: "MX", Data: "10 mail.example.com"},
+		},
+		{
+			libdns.Record{Type: "CNAME", Name: "docs", Value: "example.netlify.app"},
+			RR{Type: "CNAME", Name: "docs", Data: "example.netlify.app"},
+		},
+	}
+	for _, tt := range tests {
+		typed, err := TypedRecord(tt.flat)
+		if err != nil {
+			t.Errorf("TypedRecord(%+v): %v", tt.flat, err)
+			continue
+		}
+		if !reflect.DeepEqual(typed, tt.typed) {
+			t.Errorf("TypedRecord(%+v) = %+v, want %+v", tt.flat, typed, tt.typed)
+		}
+		if flat := FlatRecord(tt.typed); flat != tt.flat {
+			t.Errorf("FlatRecord(%+v) = %+v, want %+v", tt.typed, flat, tt.flat)
+		}
+	}
+
+	if _, err := TypedRecord(libdns.Record{Type: "AAAA", Name: "www", Value: "192.0.2.1"}); err == nil {
+		t.Error("TypedRecord accepted an IPv4 address in an AAAA record")
+	}
+}
+
+func TestTTLSeconds(t *testing.T) {
+	tests := []struct {
+		ttl  time.Duration
+		want int64
+	}{
+		{0, 0},
+		{-time.Second, 0},
+		{time.Millisecond, 1},
+		{499 * time.Millisecond, 1},
+		{500 * time.Millisecond, 1},
+		{time.Second, 1},
+		{1499 * time.Millisecond, 1},
+		{1500 * time.Millisecond, 2},
+		{59*time.Second + 499*time.Millisecond, 59},
+		{59*time.Second + 500*time.Millisecond, 60},
+		{time.Minute, 60},
+		{time.Minute + 499*time.Millisecond, 60},
+		{time.Minute + 500*time.Millisecond, 61},
+		{time.Hour - 500*time.Millisecond, 3600},
+		{time.Hour - 501*time.Millisecond, 3599},
+		{time.Hour, 3600},
+		{time.Hour + 499*time.Millisecond, 3600},
+		{24 * time.Hour, 86400},
+	}
+	for _, tt := range tests {
+		if got := ttlSeconds(tt.ttl); got != tt.want {
+			t.Errorf("ttlSeconds(%s) = %d, want %d", tt.ttl, got, tt.want)
+		}
+	}
+}
+
+func TestTTLDuration(t *testing.T) {
+	tests := []struct {
+		secs int64
+		want time.Duration
+	}{
+		{0, 0},
+		{-1, 0},
+		{1, time.Second},
+		{59, 59 * time.Second},
+		{60, time.Minute},
+		{61, time.Minute + time.Second},
+		{3599, time.Hour - time.Second},
+		{3600, time.Hour},
+		{86400, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := ttlDuration(tt.secs); got != tt.want {
+			t.Errorf("ttlDuration(%d) = %s, want %s", tt.secs, got, tt.want)
+		}
+		if tt.secs > 0 {
+			if back := ttlSeconds(ttlDuration(tt.secs)); back != tt.secs {
+				t.Errorf("ttlSeconds(ttlDuration(%d)) = %d", tt.secs, back)
+			}
+		}
+	}
+}
+
+func TestTTLRoundTrip(t *testing.T) {
+	api, p := newFakeAPI(t)
+
+	ctx := context.Background()
+	_, err := p.AppendRecords(ctx, "example.com", []libdns.Record{
+		{Type: "TXT", Name: "second", Value: "x", TTL: 1500 * time.Millisecond},
+		{Type: "TXT", Name: "minute", Value: "x", TTL: time.Minute + 400*time.Millisecond},
+		{Type: "TXT", Name: "hour", Value: "x", TTL: time.Hour - 200*time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sent []int64
+	for _, rec := range api.zoneRecords("zone1") {
+		sent = append(sent, rec.TTL)
+	}
+	if want := []int64{2, 60, 3600}; !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent TTLs %v, want %v", sent, want)
+	}
+
+	records, err := p.GetRecords(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []time.Duration
+	for _, rec := range records {
+		got = append(got, rec.TTL)
+	}
+	if want := []time.Duration{2 * time.Second, time.Minute, time.Hour}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got TTLs %v, want %v", got, want)
+	}
+}
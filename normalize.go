@@ -0,0 +1,202 @@
+package netlify
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/libdns/libdns"
+	"golang.org/x/net/idna"
+)
+
+// toASCIIName converts an internationalized domain name to its punycode form
+// as expected by Netlify. The name is returned unchanged if it can't be
+// converted
+func toASCIIName(name string) string {
+	ascii, err := idna.ToASCII(name)
+	if err != nil {
+		return name
+	}
+	return ascii
+}
+
+// toUnicodeName converts a punycode domain name returned by Netlify back to
+// its Unicode form. The name is returned unchanged if it can't be converted
+func toUnicodeName(name string) string {
+	unicode, err := idna.ToUnicode(name)
+	if err != nil {
+		return name
+	}
+	return unicode
+}
+
+// normalizeName returns the fully-qualified, lower-cased form of name in
+// zone, without the trailing dot, as used when matching records. Names with
+// a trailing dot or already ending with the zone, like the hostnames returned
+// by Netlify, are taken as fully qualified
+func normalizeName(name, zone string) string {
+	name = strings.TrimSpace(name)
+	zone = strings.TrimSuffix(strings.TrimSpace(zone), ".")
+
+	fqdn := toASCIIName(strings.ToLower(strings.TrimSuffix(name, ".")))
+	lowerZone := toASCIIName(strings.ToLower(zone))
+	if zone == "" || strings.HasSuffix(name, ".") || fqdn == lowerZone || strings.HasSuffix(fqdn, "."+lowerZone) {
+		return fqdn
+	}
+	return toASCIIName(strings.ToLower(strings.TrimSuffix(libdns.AbsoluteName(name, zone), ".")))
+}
+
+// relativeName returns hostname relative to zone, in its Unicode form, as
+// libdns expects record names. Case and trailing dots are ignored. The apex
+// of the zone is returned as an empty name, and names outside the zone are
+// returned fully qualified with a trailing dot
+func relativeName(hostname, zone string) string {
+	fqdn, apex := normalizeName(hostname, ""), normalizeName("", zone)
+	switch {
+	case fqdn == apex:
+		return ""
+	case strings.HasSuffix(fqdn, "."+apex):
+		return toUnicodeName(strings.TrimSuffix(fqdn, "."+apex))
+	}
+	return toUnicodeName(fqdn) + "."
+}
+
+// normalizeValue returns the canonical form of a record value of the given
+// type. Values holding a domain name are case-folded and lose their trailing
+// dot, IP addresses are formatted canonically and quoted TXT values are
+// unquoted; other values are only trimmed
+func normalizeValue(recType, value string) string {
+	value = strings.TrimSpace(value)
+	switch strings.ToUpper(recType) {
+	case "CNAME", "ALIAS", "NS", "MX", "PTR":
+		value = strings.ToLower(strings.TrimSuffix(value, "."))
+	case "A", "AAAA":
+		if addr, err := netip.ParseAddr(value); err == nil {
+			value = addr.String()
+		}
+	case "TXT", "SPF":
+		if joined, ok := joinTXT(value); ok {
+			value = joined
+		}
+	}
+	return value
+}
+
+// maxTXTString is the maximum length of a character string in a TXT record
+const maxTXTString = 255
+
+// joinTXT parses value as a sequence of quoted character strings, such as
+// "v=DKIM1; k=rsa; " "p=MIGf...", and returns them joined. It returns false
+// if value isn't in that form
+func joinTXT(value string) (string, bool) {
+	var b strings.Builder
+	i := 0
+	for {
+		for i < len(value) && (value[i] == ' ' || value[i] == '\t') {
+			i++
+		}
+		if i == len(value) {
+			return b.String(), i > 0
+		}
+		if value[i] != '"' {
+			return "", false
+		}
+		i++
+		for {
+			if i == len(value) {
+				return "", false
+			}
+			c := value[i]
+			if c == '"' {
+				i++
+				break
+			}
+			if c == '\\' {
+				i++
+				if i == len(value) {
+					return "", false
+				}
+				// \DDD is a byte in decimal, anything else is escaped as is
+				if i+3 <= len(value) && isDigits(value[i:i+3]) {
+					n, _ := strconv.Atoi(value[i : i+3])
+					if n > 255 {
+						return "", false
+					}
+					b.WriteByte(byte(n))
+					i += 3
+					continue
+				}
+				c = value[i]
+			}
+			b.WriteByte(c)
+			i++
+		}
+	}
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// splitTXT returns value as quoted character strings of at most 255 bytes
+// separated by spaces, without splitting UTF-8 characters. It returns value
+// as is if it fits in a single string
+func splitTXT(value string) string {
+	if len(value) <= maxTXTString {
+		return value
+	}
+
+	var chunks []string
+	for len(value) > 0 {
+		end := len(value)
+		if end > maxTXTString {
+			end = maxTXTString
+			for end > 0 && !utf8.RuneStart(value[end]) {
+				end--
+			}
+		}
+		chunk := strings.ReplaceAll(value[:end], `\`, `\\`)
+		chunk = strings.ReplaceAll(chunk, `"`, `\"`)
+		chunks = append(chunks, `"`+chunk+`"`)
+		value = value[end:]
+	}
+	return strings.Join(chunks, " ")
+}
+
+// RecordKey returns a stable key identifying record in zone. Records which
+// are considered the same by the matching logic of the provider get the
+// same key, so it can be used to index records in a map
+func RecordKey(record libdns.Record, zone string) string {
+	return strings.ToUpper(record.Type) + " " +
+		normalizeName(record.Name, zone) + " " +
+		recordContent(record)
+}
+
+// recordContent returns the canonical content of record as sent to Netlify,
+// in zone file order: the value preceded by the priority, weight, port, flag
+// and tag of the types having them
+func recordContent(record libdns.Record) string {
+	rec := netlifyRecord(record)
+	value := normalizeValue(record.Type, rec.Value)
+	switch strings.ToUpper(record.Type) {
+	case "MX":
+		return fmt.Sprintf("%d %s", rec.Priority, value)
+	case "SRV":
+		return fmt.Sprintf("%d %d %d %s", rec.Priority, rec.Weight, rec.Port, value)
+	case "CAA":
+		if rec.Tag != "" {
+			return fmt.Sprintf("%d %s %s", rec.Flag, strings.ToLower(rec.Tag), value)
+		}
+	}
+	if rec.Priority != 0 || rec.Weight != 0 {
+		return fmt.Sprintf("%d %d %s", rec.Priority, rec.Weight, value)
+	}
+	return value
+}
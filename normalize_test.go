@@ -0,0 +1,94 @@
+package netlify
+
+import (
+	"testing"
+
+	"github.com/libdns/libdns"
+)
+
+func TestRecordKeyEquivalentRecords(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b libdns.Record
+	}{
+		{
+			name: "name case",
+			a:    libdns.Record{Type: "A", Name: "WWW", Value: "192.0.2.1"},
+			b:    libdns.Record{Type: "A", Name: "www", Value: "192.0.2.1"},
+		},
+		{
+			name: "absolute name",
+			a:    libdns.Record{Type: "A", Name: "www.example.com.", Value: "192.0.2.1"},
+			b:    libdns.Record{Type: "A", Name: "www", Value: "192.0.2.1"},
+		},
+		{
+			name: "type case",
+			a:    libdns.Record{Type: "cname", Name: "www", Value: "target.example.net"},
+			b:    libdns.Record{Type: "CNAME", Name: "www", Value: "target.example.net"},
+		},
+		{
+			name: "target case and trailing dot",
+			a:    libdns.Record{Type: "CNAME", Name: "www", Value: "Target.Example.NET."},
+			b:    libdns.Record{Type: "CNAME", Name: "www", Value: "target.example.net"},
+		},
+		{
+			name: "apex",
+			a:    libdns.Record{Type: "MX", Name: "@", Value: "mail.example.com.", Priority: 10},
+			b:    libdns.Record{Type: "MX", Name: "", Value: "MAIL.example.com", Priority: 10},
+		},
+		{
+			name: "IPv6 form",
+			a:    libdns.Record{Type: "AAAA", Name: "www", Value: "2001:DB8:0:0::1"},
+			b:    libdns.Record{Type: "AAAA", Name: "www", Value: "2001:db8::1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := RecordKey(tt.a, "example.com"), RecordKey(tt.b, "Example.com.")
+			if a != b {
+				t.Errorf("got keys %q and %q, want equal keys", a, b)
+			}
+		})
+	}
+}
+
+func TestRecordKeyDistinctRecords(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b libdns.Record
+	}{
+		{
+			name: "TXT value case",
+			a:    libdns.Record{Type: "TXT", Name: "www", Value: "Token"},
+			b:    libdns.Record{Type: "TXT", Name: "www", Value: "token"},
+		},
+		{
+			name: "type",
+			a:    libdns.Record{Type: "A", Name: "www", Value: "192.0.2.1"},
+			b:    libdns.Record{Type: "AAAA", Name: "www", Value: "192.0.2.1"},
+		},
+		{
+			name: "MX priority",
+			a:    libdns.Record{Type: "MX", Name: "", Value: "mail.example.com", Priority: 10},
+			b:    libdns.Record{Type: "MX", Name: "", Value: "mail.example.com", Priority: 20},
+		},
+		{
+			name: "SRV port",
+			a:    libdns.Record{Type: "SRV", Name: "_sip._tcp", Value: "5060 sip.example.com", Priority: 10, Weight: 5},
+			b:    libdns.Record{Type: "SRV", Name: "_sip._tcp", Value: "5061 sip.example.com", Priority: 10, Weight: 5},
+		},
+		{
+			name: "CAA tag",
+			a:    libdns.Record{Type: "CAA", Name: "", Value: `0 issue "letsencrypt.org"`},
+			b:    libdns.Record{Type: "CAA", Name: "", Value: `0 issuewild "letsencrypt.org"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := RecordKey(tt.a, "example.com"), RecordKey(tt.b, "example.com")
+			if a == b {
+				t.Errorf("got the same key %q for both records, want distinct keys", a)
+			}
+		})
+	}
+}
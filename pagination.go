@@ -0,0 +1,163 @@
+package netlify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// defaultPageSize is the number of items requested per page of a list
+const defaultPageSize = 100
+
+// pageSize returns the number of items to request per page of a list
+func (p *Provider) pageSize() int {
+	if p.PageSize > 0 {
+		return p.PageSize
+	}
+	return defaultPageSize
+}
+
+type responseHeaderKey struct{}
+
+// withResponseHeader returns a copy of ctx making sendRequest store the
+// headers of the response to the request sent with it in header
+func withResponseHeader(ctx context.Context, header *http.Header) context.Context {
+	return context.WithValue(ctx, responseHeaderKey{}, header)
+}
+
+type totalCountKey struct{}
+
+// withTotalCount returns a copy of ctx making listPages store in total the
+// total count of items sent with the first page of a list, or -1 if there is
+// none
+func withTotalCount(ctx context.Context, total *int) context.Context {
+	return context.WithValue(ctx, totalCountKey{}, total)
+}
+
+// totalCount returns the total count of items of a list from the
+// X-Total-Count header of a page, or -1 if there is none
+func totalCount(header http.Header) int {
+	n, err := strconv.Atoi(strings.TrimSpace(header.Get("X-Total-Count")))
+	if err != nil || n < 0 {
+		return -1
+	}
+	return n
+}
+
+// nextPageURL returns the URL of the next page from the Link header of the
+// response to the page at current, or an empty string if there is none. It
+// returns an error if the link leaves the scheme and host of current, as the
+// access token would be sent there
+func nextPageURL(current *url.URL, header http.Header) (string, error) {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				param = strings.ReplaceAll(strings.TrimSpace(param), " ", "")
+				if param != `rel="next"` && param != "rel=next" {
+					continue
+				}
+				next, err := current.Parse(strings.Trim(target, "<>"))
+				if err != nil {
+					return "", nil
+				}
+				if !strings.EqualFold(next.Scheme, current.Scheme) || !strings.EqualFold(next.Host, current.Host) {
+					return "", fmt.Errorf("next page link %s leaves %s://%s", next.Redacted(), current.Scheme, current.Host)
+				}
+				return next.String(), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// maxPages bounds the number of pages fetched for a single list, in case the
+// API keeps linking to more pages
+const maxPages = 1000
+
+// pageIDs returns the IDs of the items of a page, to detect an API sending
+// the same page again
+func pageIDs(items []json.RawMessage) string {
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		var v struct {
+			ID string `json:"id"`
+		}
+		json.Unmarshal(item, &v)
+		ids = append(ids, v.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+// listPages gets the list at reqURL page after page, passing the items of
+// each page to page. The next page is only fetched when the response links to
+// it in its Link header; the list stops at a page which is larger than
+// requested or which repeats the previous page. It returns the first error
+// of a request or of page, or an error after maxPages pages
+func (p *Provider) listPages(ctx context.Context, reqURL string, zoneID string, isZone bool, page func(items []json.RawMessage) error) error {
+	u, err := url.Parse(reqURL)
+	if err != nil {
+		return err
+	}
+	pageSize := p.pageSize()
+	qs := u.Query()
+	qs.Set("page", "1")
+	qs.Set("per_page", strconv.Itoa(pageSize))
+	u.RawQuery = qs.Encode()
+
+	var previous string
+	for number := 1; ; number++ {
+		var header http.Header
+		req, err := http.NewRequestWithContext(withResponseHeader(ctx, &header), http.MethodGet, u.String(), nil)
+		if err != nil {
+			return err
+		}
+
+		var items []json.RawMessage
+		err = p.doAPIRequest(req, zoneID, isZone, false, true, false, &items)
+		if err != nil {
+			return err
+		}
+
+		if total, ok := ctx.Value(totalCountKey{}).(*int); ok && number == 1 {
+			*total = totalCount(header)
+		}
+
+		ids := pageIDs(items)
+		if number > 1 && len(items) > 0 && ids == previous {
+			p.logger().Warn("API sent the same page again, stopping the list",
+				"url", u.String(), "page", number)
+			return nil
+		}
+		previous = ids
+		if err := page(items); err != nil {
+			return err
+		}
+
+		// an API ignoring per_page sent the whole list at once
+		if len(items) > pageSize {
+			return nil
+		}
+		next, err := nextPageURL(u, header)
+		if err != nil {
+			return err
+		}
+		if next == "" || next == u.String() {
+			return nil
+		}
+		if number >= maxPages {
+			return fmt.Errorf("listing %s: more than %d pages", reqURL, maxPages)
+		}
+		if u, err = url.Parse(next); err != nil {
+			return err
+		}
+	}
+}
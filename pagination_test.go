@@ -0,0 +1,161 @@
+package netlify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/netlify/open-api/v2/go/models"
+)
+
+func TestGetRecordsPartialResults(t *testing.T) {
+	api, p := newFakeAPI(t)
+	p.PageSize = 2
+	for i := 1; i <= 5; i++ {
+		api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: fmt.Sprintf("192.0.2.%d", i)})
+	}
+	api.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Query().Get("page") != "2" {
+			return false
+		}
+		http.Error(w, `{"code":400,"message":"Bad Request"}`, http.StatusBadRequest)
+		return true
+	}
+
+	records, err := p.GetRecords(context.Background(), "example.com")
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	if len(records) != 2 || records[0].Value != "192.0.2.1" || records[1].Value != "192.0.2.2" {
+		t.Errorf("got %+v, want the records of the first page", records)
+	}
+}
+
+func TestListPagesIgnoredPaging(t *testing.T) {
+	api, p := newFakeAPI(t)
+	p.PageSize = 2
+	for i := 1; i <= 5; i++ {
+		api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: fmt.Sprintf("192.0.2.%d", i)})
+	}
+
+	tests := []struct {
+		name      string
+		intercept func(w http.ResponseWriter, r *http.Request) bool
+	}{
+		{
+			// the API sends every record, ignoring per_page, and
+			// links to the same page
+			name: "whole list",
+			intercept: func(w http.ResponseWriter, r *http.Request) bool {
+				if r.URL.Path != "/dns_zones/zone1/dns_records" {
+					return false
+				}
+				w.Header().Set("Link", "<"+r.URL.String()+`>; rel="next"`)
+				writeJSON(w, http.StatusOK, api.zoneRecords("zone1"))
+				return true
+			},
+		},
+		{
+			// the API sends the first page whatever page is asked
+			// for, linking to the next one
+			name: "same page",
+			intercept: func(w http.ResponseWriter, r *http.Request) bool {
+				if r.URL.Path != "/dns_zones/zone1/dns_records" {
+					return false
+				}
+				qs := r.URL.Query()
+				page, _ := strconv.Atoi(qs.Get("page"))
+				qs.Set("page", strconv.Itoa(page+1))
+				w.Header().Set("Link", "<"+r.URL.Path+"?"+qs.Encode()+`>; rel="next"`)
+				writeJSON(w, http.StatusOK, api.zoneRecords("zone1")[:2])
+				return true
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api.mu.Lock()
+			api.requests = nil
+			api.intercept = tt.intercept
+			api.mu.Unlock()
+
+			_, err := p.GetRecords(context.Background(), "example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := api.countRequests(http.MethodGet, "/dns_records"); n > 2 {
+				t.Errorf("sent %d list requests, want the list stopped", n)
+			}
+		})
+	}
+}
+
+func TestListPagesWithoutLink(t *testing.T) {
+	api, p := newFakeAPI(t)
+	p.PageSize = 2
+	for i := 1; i <= 3; i++ {
+		api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: fmt.Sprintf("192.0.2.%d", i)})
+	}
+	// a full page without a Link header is the last one
+	api.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Path != "/dns_zones/zone1/dns_records" {
+			return false
+		}
+		writeJSON(w, http.StatusOK, api.zoneRecords("zone1")[:2])
+		return true
+	}
+
+	records, err := p.GetRecords(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Errorf("got %d records, want 2", len(records))
+	}
+	if n := api.countRequests(http.MethodGet, "/dns_records"); n != 1 {
+		t.Errorf("sent %d list requests, want 1", n)
+	}
+}
+
+func TestListPagesForeignLink(t *testing.T) {
+	api, p := newFakeAPI(t)
+	p.PageSize = 2
+	for i := 1; i <= 3; i++ {
+		api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: fmt.Sprintf("192.0.2.%d", i)})
+	}
+
+	foreign := make(chan string, 1)
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case foreign <- r.Header.Get("Authorization"):
+		default:
+		}
+		writeJSON(w, http.StatusOK, []netlifyDNSRecord{})
+	}))
+	defer other.Close()
+
+	api.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Path != "/dns_zones/zone1/dns_records" {
+			return false
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<%s/dns_zones/zone1/dns_records?page=2&per_page=2>; rel="next"`, other.URL))
+		writeJSON(w, http.StatusOK, api.zoneRecords("zone1")[:2])
+		return true
+	}
+
+	records, err := p.GetRecords(context.Background(), "example.com")
+	if err == nil {
+		t.Error("got no error, want the foreign link rejected")
+	}
+	if len(records) != 2 {
+		t.Errorf("got %d records, want the 2 of the first page", len(records))
+	}
+	select {
+	case auth := <-foreign:
+		t.Errorf("sent a request to the foreign host with %q", auth)
+	default:
+	}
+}
@@ -2,9 +2,14 @@ package netlify
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/libdns/libdns"
 )
@@ -12,51 +17,529 @@ import (
 // Provider implements the libdns interfaces for Netlify.
 type Provider struct {
 	// Personnal Access Token is required to Authenticate
-	// yourself to Netlify's API
+	// yourself to Netlify's API. Defaults to the content of
+	// the NETLIFY_AUTH_TOKEN or NETLIFY_TOKEN environment
+	// variable
 	PersonnalAccessToken string `json:"api_token,omitempty"`
-	zones                map[string]netlifyZone
-	zonesMu              sync.Mutex
+
+	// ZoneTokens maps zone suffixes to the access token of the
+	// Netlify account owning the zones ending with them, for
+	// zones managed under different accounts. The longest
+	// matching suffix wins; other zones use the default token
+	ZoneTokens map[string]string `json:"zone_tokens,omitempty"`
+
+	// TokenResolver, if set, returns the access token for a
+	// zone, taking precedence over ZoneTokens. An empty token
+	// falls back to ZoneTokens and the default token
+	TokenResolver func(ctx context.Context, zone string) (string, error) `json:"-"`
+
+	// TokenFile is the path of a file holding the access
+	// token, such as a mounted Kubernetes secret, used when
+	// PersonnalAccessToken is empty. The file is read again
+	// when it changes or when Netlify rejects the token
+	TokenFile string `json:"token_file,omitempty"`
+
+	// TokenSource, if set, supplies the access token before
+	// each request instead of PersonnalAccessToken
+	TokenSource TokenSource `json:"-"`
+
+	// BaseURL is the base URL of Netlify's API, for example
+	// to go through a proxy or a mock. Defaults to
+	// https://api.netlify.com/api/ followed by APIVersion
+	BaseURL string `json:"base_url,omitempty"`
+
+	// APIVersion is the version of Netlify's API to use, such
+	// as "v1". It is ignored when BaseURL is set, which must
+	// then include it. Defaults to v1
+	APIVersion string `json:"api_version,omitempty"`
+
+	// UserAgent is appended to the User-Agent header sent
+	// with every request, to identify the product using
+	// the provider
+	UserAgent string `json:"user_agent,omitempty"`
+
+	// HTTPClient is the client used for the requests to
+	// Netlify's API. Defaults to a client owned by the
+	// provider, with its own pool of connections
+	HTTPClient *http.Client `json:"-"`
+
+	// ProxyURL is the URL of the HTTP, HTTPS or SOCKS5 proxy
+	// the requests go through, instead of the proxy set in
+	// the environment. ProxyUsername and ProxyPassword are
+	// the proxy credentials, if not part of the URL
+	ProxyURL      string `json:"proxy_url,omitempty"`
+	ProxyUsername string `json:"proxy_username,omitempty"`
+	ProxyPassword string `json:"proxy_password,omitempty"`
+
+	// TLSConfig is the TLS configuration of the requests.
+	// CABundle is the path of a PEM file with the root
+	// certificates to trust instead of the system ones, and
+	// TLSMinVersion the minimum TLS version, like "1.2"
+	TLSConfig     *tls.Config `json:"-"`
+	CABundle      string      `json:"ca_bundle,omitempty"`
+	TLSMinVersion string      `json:"tls_min_version,omitempty"`
+
+	// MaxResponseSize is the maximum size in bytes of the body
+	// of a response. Defaults to 10MB
+	MaxResponseSize int64 `json:"max_response_size,omitempty"`
+
+	// DisableCompression stops asking Netlify for gzipped
+	// responses
+	DisableCompression bool `json:"disable_compression,omitempty"`
+
+	// Middlewares wrap the transport of HTTPClient, so that
+	// every request goes through them. The first middleware
+	// sees the requests first
+	Middlewares []Middleware `json:"-"`
+
+	// DefaultTTL is the TTL of the records passed without
+	// one, such as ACME challenge records. When set, records
+	// updated by SetRecords and EnsureRecord get it too;
+	// otherwise they keep their TTL, and created records get
+	// Netlify's default of 1h
+	DefaultTTL time.Duration `json:"default_ttl,omitempty"`
+
+	// MinTTL is the minimum TTL accepted by Netlify for the
+	// account. Records with a lower TTL are raised to it with
+	// a warning, or rejected if RejectLowTTL is set. Zero
+	// disables the check
+	MinTTL       time.Duration `json:"min_ttl,omitempty"`
+	RejectLowTTL bool          `json:"reject_low_ttl,omitempty"`
+
+	// SkipIPValidation disables the check that A and AAAA
+	// record values are valid IPv4 and IPv6 addresses
+	SkipIPValidation bool `json:"skip_ip_validation,omitempty"`
+
+	// StrictValidation validates all the records passed to
+	// AppendRecords and SetRecords with ValidateRecords
+	// before sending any of them
+	StrictValidation bool `json:"strict_validation,omitempty"`
+
+	// CNAMEConflicts checks before creating a record that it
+	// won't make a CNAME record coexist with another record
+	// with the same name. It can be "warn" to log a warning
+	// or "error" to fail with ErrCNAMEConflict. Empty means
+	// no check
+	CNAMEConflicts string `json:"cname_conflicts,omitempty"`
+
+	// AllowMissingRecordID accepts records created without
+	// an ID in Netlify's response instead of failing with
+	// ErrMissingRecordID
+	AllowMissingRecordID bool `json:"allow_missing_record_id,omitempty"`
+
+	// AllowManagedRecordChanges lets the provider create,
+	// update and delete the NETLIFY and NETLIFYv6 records
+	// managed by Netlify, which are left untouched otherwise
+	AllowManagedRecordChanges bool `json:"allow_managed_record_changes,omitempty"`
+
+	// AllowDangerousNSChanges lets the provider update and
+	// delete the NS records at the apex of the zone, which
+	// could break its delegation. NS records of subdomains
+	// can always be managed
+	AllowDangerousNSChanges bool `json:"allow_dangerous_ns_changes,omitempty"`
+
+	// SortRecords sorts the records returned by GetRecords
+	// by type, name and value. They are requested sorted from
+	// the API, and sorted by the provider when they aren't
+	SortRecords bool `json:"sort_records,omitempty"`
+
+	// DryRun skips the requests creating, updating or deleting
+	// records; the records which would have been changed are
+	// still returned. It can be overridden per call with
+	// WithDryRun
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// MaxRetries is the number of times a request failing
+	// with a network error or a 5xx status, or rate limited
+	// with a 429 status, is sent again. Rate limited requests
+	// wait for the delay given by Netlify, and are retried up
+	// to 10 times within the deadline of the context even when
+	// MaxRetries is lower. Zero disables the other retries
+	MaxRetries int `json:"max_retries,omitempty"`
+
+	// DeduplicateCreates looks for an identical record
+	// before retrying a failed create, in case Netlify applied
+	// it anyway, so that retries don't create duplicates
+	DeduplicateCreates bool `json:"deduplicate_creates,omitempty"`
+
+	// RetryBackoff is the maximum delay before the first
+	// retry, doubled for each following retry; the actual
+	// delay is random. Defaults to 500ms
+	RetryBackoff time.Duration `json:"retry_backoff,omitempty"`
+
+	// RetryPolicy decides which failed requests are sent
+	// again and when, replacing MaxRetries and RetryBackoff
+	RetryPolicy RetryPolicy `json:"-"`
+
+	// RequestTimeout bounds each attempt of a request to
+	// Netlify's API. OperationTimeout bounds each call to the
+	// provider, whatever the context passed by the caller.
+	// Zero means no timeout
+	RequestTimeout   time.Duration `json:"request_timeout,omitempty"`
+	OperationTimeout time.Duration `json:"operation_timeout,omitempty"`
+
+	// CircuitBreakerThreshold is the number of consecutive
+	// transient failures after which requests fail fast with
+	// ErrCircuitOpen for CircuitBreakerCooldown (30s by
+	// default). Zero disables the circuit breaker
+	CircuitBreakerThreshold int           `json:"circuit_breaker_threshold,omitempty"`
+	CircuitBreakerCooldown  time.Duration `json:"circuit_breaker_cooldown,omitempty"`
+
+	// RateLimit is the maximum number of requests per second
+	// sent to Netlify's API with each access token. Zero means
+	// no limit
+	RateLimit float64 `json:"rate_limit,omitempty"`
+
+	// ShareRateLimit makes every provider in the process using
+	// the same access token share its RateLimit limiter and
+	// the rate limit reported by Netlify, so that they don't
+	// exhaust the token's quota independently. The limiter
+	// uses the RateLimit of the first provider to create it
+	ShareRateLimit bool `json:"share_rate_limit,omitempty"`
+
+	// ZoneRateLimit is the maximum number of requests per
+	// second sent for a single zone, applied independently
+	// for each zone on top of RateLimit. Zero means no limit
+	ZoneRateLimit float64 `json:"zone_rate_limit,omitempty"`
+
+	// MaxConcurrentRequests is the maximum number of requests
+	// in flight at the same time. Zero means no limit
+	MaxConcurrentRequests int `json:"max_concurrent_requests,omitempty"`
+
+	// MinWriteInterval sends the requests creating, updating
+	// or deleting records one at a time, at least this long
+	// apart, to smooth out bursts of changes. Zero disables
+	// the write queue
+	MinWriteInterval time.Duration `json:"min_write_interval,omitempty"`
+
+	// CheckResponseErrors treats successful responses with
+	// a non-empty error field in their body as errors
+	CheckResponseErrors bool `json:"check_response_errors,omitempty"`
+
+	// RecordsCacheTTL enables caching the records of each
+	// zone for this long. Any change to the records of a zone
+	// drops its cached records. Zero disables the cache
+	RecordsCacheTTL time.Duration `json:"records_cache_ttl,omitempty"`
+
+	// PageSize is the number of records or zones requested
+	// per page when listing them. Defaults to 100
+	PageSize int `json:"page_size,omitempty"`
+
+	// BeforeMutate is called before each record is created,
+	// updated or deleted. Returning an error aborts the
+	// operation with that error
+	BeforeMutate func(ctx context.Context, op Operation, record libdns.Record) error `json:"-"`
+
+	// ConditionalRequests caches the lists of zones and records
+	// with their ETag or Last-Modified header, and revalidates
+	// them with conditional requests, using the cached body
+	// when Netlify answers 304 Not Modified
+	ConditionalRequests bool `json:"conditional_requests,omitempty"`
+
+	// Logger receives the warnings and debug messages of the
+	// provider, such as a *slog.Logger. Defaults to discarding
+	// them
+	Logger Logger `json:"-"`
+
+	// TraceRequests logs the DNS lookup, connection, TLS
+	// handshake and first byte timings of each request at
+	// the debug level
+	TraceRequests bool `json:"trace_requests,omitempty"`
+
+	// OnRequestTiming, if set, is called with the timings of
+	// each request once its response headers are received
+	OnRequestTiming func(RequestTiming) `json:"-"`
+
+	// Clock is the source of time used for rate limiting,
+	// retries and caching. Defaults to the system clock
+	Clock Clock `json:"-"`
+
+	client   *http.Client
+	clientMu sync.Mutex
+
+	tokenFile tokenFile
+
+	zones   map[cacheKey]netlifyZone
+	zonesMu sync.Mutex
+
+	records   map[cacheKey]cachedRecords
+	recordsMu sync.Mutex
+
+	conditional     map[conditionalKey]conditionalEntry
+	conditionalUses uint64
+	conditionalMu   sync.Mutex
+
+	cacheCounters cacheCounters
+
+	limiters     map[tokenHash]*rateLimiter
+	zoneLimiters map[string]*rateLimiter
+	requestSlots chan struct{}
+	writeQueue   writeQueue
+	limitersMu   sync.Mutex
+	rateTrackers map[tokenHash]*rateTracker
+	breaker      circuitBreaker
+}
+
+// Operation is a change made to a record, as passed to BeforeMutate.
+type Operation string
+
+const (
+	OpCreate Operation = "create"
+	OpUpdate Operation = "update"
+	OpDelete Operation = "delete"
+)
+
+// beforeMutate calls the BeforeMutate hook, if any
+func (p *Provider) beforeMutate(ctx context.Context, op Operation, record libdns.Record) error {
+	if p.BeforeMutate == nil {
+		return nil
+	}
+	return p.BeforeMutate(ctx, op, record)
 }
 
-// GetRecords lists all the records in the zone.
+// GetRecords lists all the records in the zone. If a page of the records
+// can't be fetched, it returns the records of the previous pages along with
+// the error.
 func (p *Provider) GetRecords(ctx context.Context, zone string) ([]libdns.Record, error) {
+	ctx, cancel := p.startOperation(ctx, zone)
+	defer cancel()
+
 	zoneInfo, err := p.getZoneInfo(ctx, zone)
 	if err != nil {
 		return nil, err
 	}
 
-	reqURL := fmt.Sprintf("%s/dns_zones/%s/dns_records", baseURL, zoneInfo.ID)
-	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	result, err := p.listDNSRecords(ctx, zoneInfo)
+
+	recs := make([]libdns.Record, 0, len(result))
+	for _, rec := range result {
+		recs = append(recs, rec.libdnsRecord(zone))
+	}
+
+	// the records were requested sorted; sort them here if the API or
+	// the records cache didn't
+	less := func(i, j int) bool {
+		return RecordKey(recs[i], zone) < RecordKey(recs[j], zone)
+	}
+	if p.SortRecords && !sort.SliceIsSorted(recs, less) {
+		sort.SliceStable(recs, less)
+	}
+
+	return recs, err
+}
+
+// GetRecordByID gets the record of the zone with the given Netlify ID, such as
+// the ID of a record returned by GetRecords, without listing the zone. If
+// there is no such record, it returns an error for which IsNotFound is true.
+func (p *Provider) GetRecordByID(ctx context.Context, zone string, id string) (libdns.Record, error) {
+	ctx, cancel := p.startOperation(ctx, zone)
+	defer cancel()
+
+	zoneInfo, err := p.getZoneInfo(ctx, zone)
+	if err != nil {
+		return libdns.Record{}, err
+	}
+
+	rec, err := p.getDNSRecord(ctx, zoneInfo.ID, id)
+	if err != nil {
+		return libdns.Record{}, err
+	}
+	return rec.libdnsRecord(zone), nil
+}
+
+// RecordFilter selects the records of a zone by name and type. An empty
+// field matches any record.
+type RecordFilter struct {
+	Name string
+	Type string
+}
+
+// GetRecordsFiltered lists the records in the zone matching each filter. The
+// zone is listed once for all the filters. It returns the matching records
+// of each filter.
+func (p *Provider) GetRecordsFiltered(ctx context.Context, zone string, filters []RecordFilter) (map[RecordFilter][]libdns.Record, error) {
+	records, err := p.GetRecords(ctx, zone)
 	if err != nil {
 		return nil, err
 	}
 
-	var result []netlifyDNSRecord
-	err = p.doAPIRequest(req, false, false, true, false, &result)
+	matches := make(map[RecordFilter][]libdns.Record, len(filters))
+	for _, filter := range filters {
+		var filtered []libdns.Record
+		for _, rec := range records {
+			if filter.Name != "" && normalizeName(rec.Name, zone) != normalizeName(filter.Name, zone) {
+				continue
+			}
+			if filter.Type != "" && !strings.EqualFold(rec.Type, filter.Type) {
+				continue
+			}
+			filtered = append(filtered, rec)
+		}
+		matches[filter] = filtered
+	}
+
+	return matches, nil
+}
+
+// StreamRecords lists all the records in the zone and sends them on the
+// returned record channel, page after page as they are fetched unless
+// SortRecords is set. Both channels are closed once all the records have
+// been sent, after an error was sent on the error channel, or when ctx is
+// done.
+func (p *Provider) StreamRecords(ctx context.Context, zone string) (<-chan libdns.Record, <-chan error) {
+	ctx, cancel := p.startOperation(ctx, zone)
+
+	recsCh := make(chan libdns.Record)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer cancel()
+		defer close(recsCh)
+		defer close(errCh)
+
+		send := func(records []libdns.Record) error {
+			for _, rec := range records {
+				select {
+				case recsCh <- rec:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		}
+
+		var err error
+		if p.SortRecords {
+			var records []libdns.Record
+			if records, err = p.GetRecords(ctx, zone); err == nil {
+				err = send(records)
+			}
+		} else {
+			var zoneInfo netlifyZone
+			if zoneInfo, err = p.getZoneInfo(ctx, zone); err == nil {
+				err = p.pageDNSRecords(ctx, zoneInfo, func(page []netlifyDNSRecord) error {
+					records := make([]libdns.Record, 0, len(page))
+					for _, rec := range page {
+						records = append(records, rec.libdnsRecord(zone))
+					}
+					return send(records)
+				})
+			}
+		}
+		if err != nil {
+			errCh <- err
+		}
+	}()
+
+	return recsCh, errCh
+}
+
+// errCounted stops listing the records once their total count is known
+var errCounted = errors.New("records counted")
+
+// CountRecords returns the number of records in the zone. It uses the total
+// count sent with the first page of the records when there is one, and
+// otherwise lists and counts the records.
+func (p *Provider) CountRecords(ctx context.Context, zone string) (int, error) {
+	ctx, cancel := p.startOperation(ctx, zone)
+	defer cancel()
+
+	zoneInfo, err := p.getZoneInfo(ctx, zone)
+	if err != nil {
+		return 0, err
+	}
+
+	total, count := -1, 0
+	err = p.pageDNSRecords(withTotalCount(ctx, &total), zoneInfo, func(records []netlifyDNSRecord) error {
+		// the total of a parent zone also counts the records outside
+		// the zone
+		if total >= 0 && !zoneInfo.inParent {
+			return errCounted
+		}
+		count += len(records)
+		return nil
+	})
+	if errors.Is(err, errCounted) {
+		return total, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// RecordGroupKey identifies a group of records sharing the same name and
+// type, as returned by GetRecordsGrouped.
+type RecordGroupKey struct {
+	Name string
+	Type string
+}
+
+// GetRecordsGrouped lists all the records in the zone, grouped by name and type.
+// Names are normalized and relative to the zone. Values are canonical and
+// preceded by the priority, weight and port of MX and SRV records and the
+// flag and tag of CAA records, as in zone files; they are sorted in each group.
+func (p *Provider) GetRecordsGrouped(ctx context.Context, zone string) (map[RecordGroupKey][]string, error) {
+	records, err := p.GetRecords(ctx, zone)
 	if err != nil {
 		return nil, err
 	}
 
-	recs := make([]libdns.Record, 0, len(result))
-	for _, rec := range result {
-		recs = append(recs, rec.libdnsRecord(zone))
+	groups := make(map[RecordGroupKey][]string)
+	for _, rec := range records {
+		key := RecordGroupKey{
+			Name: libdns.RelativeName(normalizeName(rec.Name, zone), normalizeName("", zone)),
+			Type: strings.ToUpper(rec.Type),
+		}
+		groups[key] = append(groups[key], recordContent(rec))
+	}
+	for _, values := range groups {
+		sort.Strings(values)
 	}
 
-	return recs, nil
+	return groups, nil
 }
 
-// AppendRecords adds records to the zone. It returns the records that were added.
+// ListZones returns the DNS zones the access token can manage, with their
+// names fully qualified.
+func (p *Provider) ListZones(ctx context.Context) ([]libdns.Zone, error) {
+	ctx, cancel := p.startOperation(ctx, "")
+	defer cancel()
+
+	result, err := p.listAllZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	zones := make([]libdns.Zone, 0, len(result))
+	for _, zone := range result {
+		zones = append(zones, libdns.Zone{Name: toUnicodeName(zone.Name) + "."})
+	}
+	return zones, nil
+}
+
+// AppendRecords adds records to the zone. It returns the records that were added,
+// with the TTL Netlify applied.
 func (p *Provider) AppendRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
-	zoneInfo, err := p.getZoneInfo(ctx, zone)
+	ctx, cancel := p.startOperation(ctx, zone)
+	defer cancel()
+
+	if p.StrictValidation {
+		if err := p.ValidateRecords(records); err != nil {
+			return nil, err
+		}
+	}
+
+	zoneInfo, err := p.getWritableZoneInfo(ctx, zone)
 	if err != nil {
 		return nil, err
 	}
 
 	var created []libdns.Record
-	for _, rec := range records {
+	for i, rec := range records {
 		result, err := p.createRecord(ctx, zoneInfo, rec)
 		if err != nil {
-			return nil, err
+			return nil, batchError(i, rec, err)
 		}
 		created = append(created, result.libdnsRecord(zone))
 	}
@@ -64,103 +547,436 @@ func (p *Provider) AppendRecords(ctx context.Context, zone string, records []lib
 	return created, nil
 }
 
-// DeleteRecords deletes the records from the zone. If a record does not have an ID,
-// it will be looked up. It returns the records that were deleted.
+// DeleteRecords deletes the records from the zone. Records with an ID, such as
+// those returned by GetRecords, are fetched and deleted by ID; the others are
+// looked up by name, type and, if set, value. Records managed by Netlify are
+// skipped, see IsManagedRecord, and records which don't exist are ignored. It
+// returns the records that were deleted, as they were in the zone.
 func (p *Provider) DeleteRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
-	zoneInfo, err := p.getZoneInfo(ctx, zone)
+	result, err := p.DeleteRecordsDetailed(ctx, zone, records)
 	if err != nil {
 		return nil, err
 	}
+	return result.Deleted, nil
+}
 
-	var recs []libdns.Record
-	for _, rec := range records {
-		// we create a "delete queue" for each record
-		// requested for deletion; if the record ID
-		// is known, that is the only one to fill the
-		// queue, but if it's not known, we try to find
-		// a match theoretically there could be more
-		// than one
-		var deleteQueue []libdns.Record
-
-		if rec.ID == "" {
-			// record ID is required; try to find it with what was provided
-			exactMatches, err := p.getDNSRecords(ctx, zoneInfo, rec, false)
-			if err != nil {
-				return nil, err
+// DeleteResult is the outcome of DeleteRecordsDetailed.
+type DeleteResult struct {
+	// Deleted are the records that were deleted, as they were in
+	// the zone
+	Deleted []libdns.Record
+	// Missing are the records which didn't exist, such as because
+	// they were already deleted by a concurrent cleanup. They are
+	// not an error
+	Missing []libdns.Record
+}
+
+// DeleteRecordsDetailed deletes the records from the zone as DeleteRecords
+// does, and also reports the records which didn't exist.
+func (p *Provider) DeleteRecordsDetailed(ctx context.Context, zone string, records []libdns.Record) (DeleteResult, error) {
+	ctx, cancel := p.startOperation(ctx, zone)
+	defer cancel()
+
+	zoneInfo, err := p.getWritableZoneInfo(ctx, zone)
+	if err != nil {
+		return DeleteResult{}, err
+	}
+
+	var result DeleteResult
+	for i, rec := range records {
+		// the ID designates the exact record to delete, even in a
+		// set of records with the same name and type; without it,
+		// every record matching what was provided is deleted
+		var deleteQueue []netlifyDNSRecord
+		if rec.ID != "" {
+			// the record is fetched so that the guards check what
+			// is actually deleted, not what the caller passed
+			current, err := p.getDNSRecord(ctx, zoneInfo.ID, rec.ID)
+			if errors.Is(err, ErrRecordNotFound) {
+				result.Missing = append(result.Missing, rec)
+				continue
 			}
-			for _, rec := range exactMatches {
-				deleteQueue = append(deleteQueue, rec.libdnsRecord(zone))
+			if err != nil {
+				var missing bool
+				if missing, err = p.recordMissing(ctx, zoneInfo, err); missing {
+					result.Missing = append(result.Missing, rec)
+					continue
+				}
+				return DeleteResult{}, batchError(i, rec, err)
 			}
+			deleteQueue = []netlifyDNSRecord{current}
 		} else {
-			deleteQueue = []libdns.Record{rec}
+			deleteQueue, err = p.getDNSRecords(ctx, zoneInfo, rec, rec.Value != "")
+			if errors.Is(err, ErrRecordNotFound) {
+				result.Missing = append(result.Missing, rec)
+				continue
+			}
+			if err != nil {
+				return DeleteResult{}, batchError(i, rec, err)
+			}
 		}
+		deleteQueue = p.unprotected(deleteQueue)
 
 		for _, delRec := range deleteQueue {
-			reqURL := fmt.Sprintf("%s/dns_zones/%s/dns_records/%s", baseURL, zoneInfo.ID, delRec.ID)
-
-			req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
-			var result netlifyDNSRecord
-			err = p.doAPIRequest(req, false, false, true, true, &result)
+			deleted, err := p.deleteRecord(ctx, zoneInfo, zone, delRec)
 			if err != nil {
-				return nil, err
+				return DeleteResult{}, batchError(i, rec, err)
+			}
+			if deleted {
+				result.Deleted = append(result.Deleted, delRec.libdnsRecord(zone))
+			} else {
+				result.Missing = append(result.Missing, delRec.libdnsRecord(zone))
 			}
+		}
+	}
 
-			recs = append(recs, result.libdnsRecord(zone))
+	return result, nil
+}
 
-			req, err = http.NewRequestWithContext(ctx, "DELETE", reqURL, nil)
+// EnsureAbsent makes sure the zone has none of the records, looking them up
+// by name, type and, if set, value; their IDs are ignored. Records without a
+// type match records of any type. Records which don't exist are ignored, and
+// records managed by Netlify are skipped. It returns the records that were
+// deleted.
+func (p *Provider) EnsureAbsent(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
+	ctx, cancel := p.startOperation(ctx, zone)
+	defer cancel()
 
-			err = p.doAPIRequest(req, false, true, false, true, &result)
+	zoneInfo, err := p.getWritableZoneInfo(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []libdns.Record
+	for i, rec := range records {
+		rec.ID = ""
+		var matches []netlifyDNSRecord
+		if rec.Type == "" {
+			named, err := p.getRecordsNamed(ctx, zoneInfo, rec.Name)
+			if err != nil {
+				return deleted, batchError(i, rec, err)
+			}
+			for _, cur := range named {
+				if rec.Value == "" || normalizeValue(cur.Type, cur.Value) == normalizeValue(cur.Type, rec.Value) {
+					matches = append(matches, cur)
+				}
+			}
+		} else {
+			matches, err = p.getDNSRecords(ctx, zoneInfo, rec, rec.Value != "")
+			if errors.Is(err, ErrRecordNotFound) {
+				continue
+			}
 			if err != nil {
-				return nil, err
+				return deleted, batchError(i, rec, err)
 			}
 		}
 
+		for _, delRec := range p.unprotected(matches) {
+			ok, err := p.deleteRecord(ctx, zoneInfo, zone, delRec)
+			if err != nil {
+				return deleted, batchError(i, rec, err)
+			}
+			if ok {
+				deleted = append(deleted, delRec.libdnsRecord(zone))
+			}
+		}
 	}
 
-	return recs, nil
+	return deleted, nil
 }
 
-// SetRecords sets the records in the zone, either by updating existing records
-// or creating new ones. It returns the updated records.
+// SetRecords sets the records in the zone so that, for each name and type in
+// the input, the zone has exactly the records of the input: existing records
+// with the same value are kept, the other existing records are updated in
+// place or deleted, and the missing ones are created. Records with other names
+// or types are not affected. It returns the input records as set in the zone.
 func (p *Provider) SetRecords(ctx context.Context, zone string, records []libdns.Record) ([]libdns.Record, error) {
-	zoneInfo, err := p.getZoneInfo(ctx, zone)
+	ctx, cancel := p.startOperation(ctx, zone)
+	defer cancel()
+
+	if err := requireTypes(records); err != nil {
+		return nil, err
+	}
+
+	if p.StrictValidation {
+		if err := p.ValidateRecords(records); err != nil {
+			return nil, err
+		}
+	}
+
+	zoneInfo, err := p.getWritableZoneInfo(ctx, zone)
 	if err != nil {
 		return nil, err
 	}
 
-	var results []libdns.Record
-	for _, rec := range records {
-		oldRec := netlifyRecord(rec)
-		oldRec.DNSZoneID = zoneInfo.ID
-		if rec.ID == "" {
-			// the record might already exist, even if we don't know the ID yet
-			matches, err := p.getDNSRecords(ctx, zoneInfo, rec, false)
-			if err != nil {
-				return nil, err
-			}
-			if len(matches) == 0 {
-				// record doesn't exist; create it
-				result, err := p.createRecord(ctx, zoneInfo, rec)
-				if err != nil {
-					return nil, err
-				}
-				results = append(results, result.libdnsRecord(zone))
+	results := make([]UpsertResult, len(records))
+	for _, set := range rrsets(zone, records) {
+		if err := p.setRRset(ctx, zoneInfo, zone, records, set, results); err != nil {
+			return nil, err
+		}
+	}
+
+	set := make([]libdns.Record, len(results))
+	for i, result := range results {
+		set[i] = result.Record
+	}
+	return set, nil
+}
+
+// rrsets groups the indexes of records by name and type, in the order the
+// names and types first appear
+func rrsets(zone string, records []libdns.Record) [][]int {
+	var sets [][]int
+	index := make(map[string]int)
+	for i, rec := range records {
+		key := normalizeName(rec.Name, zone) + " " + strings.ToUpper(rec.Type)
+		j, ok := index[key]
+		if !ok {
+			j = len(sets)
+			index[key] = j
+			sets = append(sets, nil)
+		}
+		sets[j] = append(sets[j], i)
+	}
+	return sets
+}
+
+// setRRset makes the records of the zone with the name and type of the
+// records at the indexes set match them, as described by SetRecords. The
+// resulting records are stored at the same indexes of results
+func (p *Provider) setRRset(ctx context.Context, zoneInfo netlifyZone, zone string, records []libdns.Record, set []int, results []UpsertResult) error {
+	leftover, err := p.upsertRRset(ctx, zoneInfo, zone, records, set, results)
+	if err != nil {
+		return err
+	}
+
+	// the existing records left over are no longer part of the set
+	first := records[set[0]]
+	for _, cur := range leftover {
+		if _, err := p.deleteRecord(ctx, zoneInfo, zone, cur); err != nil {
+			return batchError(set[0], first, err)
+		}
+	}
+	return nil
+}
+
+// upsertRRset makes sure the zone has the records at the indexes set, which
+// share a name and type. They are paired with the existing records with the
+// same ID or value, then with the remaining existing records, which are
+// updated; the records left without a pair are created. The resulting
+// records and the actions taken are stored at the same indexes of results.
+// It returns the existing records left over
+func (p *Provider) upsertRRset(ctx context.Context, zoneInfo netlifyZone, zone string, records []libdns.Record, set []int, results []UpsertResult) ([]netlifyDNSRecord, error) {
+	first := records[set[0]]
+	existing, err := p.getDNSRecords(ctx, zoneInfo, libdns.Record{Name: first.Name, Type: first.Type}, false)
+	if err != nil && !errors.Is(err, ErrRecordNotFound) {
+		return nil, batchError(set[0], first, err)
+	}
+	existing = p.unprotected(existing)
+
+	// pair the records with the existing records with the same ID or
+	// value, then with the remaining existing records
+	used := make([]bool, len(existing))
+	pairs := make(map[int]int)
+	for _, i := range set {
+		rec := records[i]
+		for j, cur := range existing {
+			if used[j] {
 				continue
 			}
-			if len(matches) > 1 {
-				return nil, fmt.Errorf("unexpectedly found more than 1 record for %v", rec)
+			if (rec.ID != "" && cur.ID == rec.ID) ||
+				(rec.ID == "" && sameValue(cur, rec)) {
+				used[j] = true
+				pairs[i] = j
+				break
 			}
-			// record does exist, fill in the ID so that we can update it
-			oldRec.ID = matches[0].ID
 		}
-		// record exists; update it
-		result, err := p.updateRecord(ctx, oldRec, netlifyRecord(rec))
+	}
+	for _, i := range set {
+		if _, ok := pairs[i]; ok {
+			continue
+		}
+		for j := range existing {
+			if !used[j] {
+				used[j] = true
+				pairs[i] = j
+				break
+			}
+		}
+	}
+
+	for _, i := range set {
+		rec := records[i]
+		j, ok := pairs[i]
+		if !ok {
+			result, err := p.createRecord(ctx, zoneInfo, rec)
+			if err != nil {
+				return nil, batchError(i, rec, err)
+			}
+			results[i] = UpsertResult{Record: result.libdnsRecord(zone), Action: RecordCreated}
+			continue
+		}
+
+		current := existing[j]
+		ttl, err := p.applyTTL(rec, false)
+		if err != nil {
+			return nil, batchError(i, rec, err)
+		}
+		rec.TTL = ttl
+		if unchanged(current, rec) {
+			p.logger().Debug("record unchanged, skipping update",
+				"name", rec.Name, "type", rec.Type, "id", current.ID)
+			results[i] = UpsertResult{Record: current.libdnsRecord(zone), Action: RecordUnchanged}
+			continue
+		}
+		if err := p.validateRecord(rec, zoneInfo.Name); err != nil {
+			return nil, batchError(i, rec, err)
+		}
+		if err := p.checkNSChange(zoneInfo, current); err != nil {
+			return nil, batchError(i, rec, err)
+		}
+		if err := p.beforeMutate(ctx, OpUpdate, rec); err != nil {
+			return nil, batchError(i, rec, err)
+		}
+		oldRec := zoneInfo.record(rec)
+		oldRec.ID = current.ID
+		result, err := p.updateRecord(ctx, oldRec, zoneInfo.record(rec))
+		if err != nil {
+			return nil, batchError(i, rec, err)
+		}
+		results[i] = UpsertResult{Record: result.libdnsRecord(zone), Action: RecordUpdated}
+	}
+
+	var leftover []netlifyDNSRecord
+	for j, cur := range existing {
+		if !used[j] {
+			leftover = append(leftover, cur)
+		}
+	}
+	return leftover, nil
+}
+
+// EnsureAction tells what EnsureRecord did to the zone.
+type EnsureAction int
+
+const (
+	// RecordUnchanged means the record already existed as requested.
+	RecordUnchanged EnsureAction = iota
+	// RecordCreated means the record didn't exist and was created.
+	RecordCreated
+	// RecordUpdated means the record existed and was updated.
+	RecordUpdated
+)
+
+func (a EnsureAction) String() string {
+	switch a {
+	case RecordCreated:
+		return "created"
+	case RecordUpdated:
+		return "updated"
+	default:
+		return "unchanged"
+	}
+}
+
+// EnsureRecord makes sure the zone has the record, looking it up by name and
+// type. It creates the record if it doesn't exist and updates it if it
+// differs. It returns the resulting record and the action taken.
+func (p *Provider) EnsureRecord(ctx context.Context, zone string, record libdns.Record) (libdns.Record, EnsureAction, error) {
+	ctx, cancel := p.startOperation(ctx, zone)
+	defer cancel()
+
+	if err := requireTypes([]libdns.Record{record}); err != nil {
+		return libdns.Record{}, RecordUnchanged, err
+	}
+
+	zoneInfo, err := p.getWritableZoneInfo(ctx, zone)
+	if err != nil {
+		return libdns.Record{}, RecordUnchanged, err
+	}
+
+	matches, err := p.getDNSRecords(ctx, zoneInfo, record, false)
+	if err != nil && !errors.Is(err, ErrRecordNotFound) {
+		return libdns.Record{}, RecordUnchanged, err
+	}
+	matches = p.unprotected(matches)
+	if len(matches) == 0 {
+		result, err := p.createRecord(ctx, zoneInfo, record)
 		if err != nil {
+			return libdns.Record{}, RecordUnchanged, err
+		}
+		return result.libdnsRecord(zone), RecordCreated, nil
+	}
+	if len(matches) > 1 {
+		return libdns.Record{}, RecordUnchanged, fmt.Errorf("unexpectedly found more than 1 record for %v", record)
+	}
+
+	current := matches[0]
+	ttl, err := p.applyTTL(record, false)
+	if err != nil {
+		return libdns.Record{}, RecordUnchanged, err
+	}
+	record.TTL = ttl
+	if unchanged(current, record) {
+		return current.libdnsRecord(zone), RecordUnchanged, nil
+	}
+
+	if err := p.validateRecord(record, zoneInfo.Name); err != nil {
+		return libdns.Record{}, RecordUnchanged, err
+	}
+	if err := p.checkNSChange(zoneInfo, current); err != nil {
+		return libdns.Record{}, RecordUnchanged, err
+	}
+	if err := p.beforeMutate(ctx, OpUpdate, record); err != nil {
+		return libdns.Record{}, RecordUnchanged, err
+	}
+	oldRec := zoneInfo.record(record)
+	oldRec.ID = current.ID
+	result, err := p.updateRecord(ctx, oldRec, zoneInfo.record(record))
+	if err != nil {
+		return libdns.Record{}, RecordUnchanged, err
+	}
+	return result.libdnsRecord(zone), RecordUpdated, nil
+}
+
+// UpsertResult is the outcome of upserting a record with UpsertRecords.
+type UpsertResult struct {
+	Record libdns.Record
+	Action EnsureAction
+}
+
+// UpsertRecords makes sure the zone has the records, looking them up by name
+// and type. The records with the same name and type are paired with the
+// existing ones as SetRecords pairs them: existing records with the same ID
+// or value are kept, and the other existing records are updated in place.
+// The records left without a pair are created, and the existing records left
+// over are not affected. It returns the resulting record and the action
+// taken for each record, in the same order.
+func (p *Provider) UpsertRecords(ctx context.Context, zone string, records []libdns.Record) ([]UpsertResult, error) {
+	ctx, cancel := p.startOperation(ctx, zone)
+	defer cancel()
+
+	if err := requireTypes(records); err != nil {
+		return nil, err
+	}
+
+	if p.StrictValidation {
+		if err := p.ValidateRecords(records); err != nil {
 			return nil, err
 		}
-		results = append(results, result.libdnsRecord(zone))
 	}
 
+	zoneInfo, err := p.getWritableZoneInfo(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]UpsertResult, len(records))
+	for _, set := range rrsets(zone, records) {
+		if _, err := p.upsertRRset(ctx, zoneInfo, zone, records, set, results); err != nil {
+			return nil, err
+		}
+	}
 	return results, nil
 }
 
@@ -170,4 +986,5 @@ var (
 	_ libdns.RecordAppender = (*Provider)(nil)
 	_ libdns.RecordSetter   = (*Provider)(nil)
 	_ libdns.RecordDeleter  = (*Provider)(nil)
+	_ libdns.ZoneLister     = (*Provider)(nil)
 )
@@ -0,0 +1,591 @@
+package netlify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/netlify/open-api/v2/go/models"
+)
+
+func TestGetRecordsGrouped(t *testing.T) {
+	api, p := newFakeAPI(t)
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: "192.0.2.3", TTL: 3600})
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "WWW.example.com", Value: "192.0.2.1", TTL: 3600})
+	api.addRecord("zone1", models.DNSRecord{Type: "AAAA", Hostname: "www.example.com", Value: "2001:db8::1", TTL: 3600})
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: "192.0.2.2", TTL: 3600})
+	api.addRecord("zone1", models.DNSRecord{Type: "MX", Hostname: "example.com", Value: "mail.example.com", Priority: 10, TTL: 3600})
+
+	groups, err := p.GetRecordsGrouped(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[RecordGroupKey][]string{
+		{Name: "www", Type: "A"}:    {"192.0.2.1", "192.0.2.2", "192.0.2.3"},
+		{Name: "www", Type: "AAAA"}: {"2001:db8::1"},
+		{Name: "", Type: "MX"}:      {"10 mail.example.com"},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("got groups %v, want %v", groups, want)
+	}
+}
+
+func TestCountRecords(t *testing.T) {
+	api, p := newFakeAPI(t)
+	p.PageSize = 2
+	for i := 1; i <= 5; i++ {
+		api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: fmt.Sprintf("192.0.2.%d", i)})
+	}
+
+	n, err := p.CountRecords(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Errorf("got %d records, want 5", n)
+	}
+	if n := api.countRequests(http.MethodGet, "/dns_records"); n != 3 {
+		t.Errorf("sent %d list requests, want 3 pages", n)
+	}
+}
+
+func TestCountRecordsTotalCount(t *testing.T) {
+	api, p := newFakeAPI(t)
+	p.PageSize = 2
+	for i := 1; i <= 5; i++ {
+		api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: fmt.Sprintf("192.0.2.%d", i)})
+	}
+	api.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		w.Header().Set("X-Total-Count", "42")
+		return false
+	}
+
+	n, err := p.CountRecords(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 42 {
+		t.Errorf("got %d records, want the total count of 42", n)
+	}
+	if n := api.countRequests(http.MethodGet, "/dns_records"); n != 1 {
+		t.Errorf("sent %d list requests, want the first page only", n)
+	}
+}
+
+func TestGetRecordsSorted(t *testing.T) {
+	api, p := newFakeAPI(t)
+	p.SortRecords = true
+	api.addRecord("zone1", models.DNSRecord{Type: "TXT", Hostname: "b.example.com", Value: "x"})
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: "192.0.2.2"})
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "a.example.com", Value: "192.0.2.1"})
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: "192.0.2.1"})
+
+	var queries []string
+	api.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if strings.HasSuffix(r.URL.Path, "/dns_records") {
+			queries = append(queries, r.URL.RawQuery)
+		}
+		return false
+	}
+
+	records, err := p.GetRecords(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, rec := range records {
+		got = append(got, rec.Type+" "+rec.Name+" "+rec.Value)
+	}
+	want := []string{"A a 192.0.2.1", "A www 192.0.2.1", "A www 192.0.2.2", "TXT b x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got records %q, want %q", got, want)
+	}
+
+	// the fake API ignores the sort parameter, so the records were sorted
+	// by the provider
+	if len(queries) != 1 || !strings.Contains(queries[0], "sort=type%2Chostname%2Cvalue") {
+		t.Errorf("sent the queries %q, want the sort parameter", queries)
+	}
+
+	p.SortRecords = false
+	queries = nil
+	if _, err := p.GetRecords(context.Background(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if len(queries) != 1 || strings.Contains(queries[0], "sort") {
+		t.Errorf("sent the queries %q, want no sort parameter", queries)
+	}
+}
+
+func TestStreamRecords(t *testing.T) {
+	api, p := newFakeAPI(t)
+	p.PageSize = 2
+	for i := 1; i <= 3; i++ {
+		api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: fmt.Sprintf("192.0.2.%d", i)})
+	}
+
+	recsCh, errCh := p.StreamRecords(context.Background(), "example.com")
+	var values []string
+	for rec := range recsCh {
+		values = append(values, rec.Value)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("got values %v, want %v", values, want)
+	}
+	if n := api.countRequests(http.MethodGet, "/dns_records"); n != 2 {
+		t.Errorf("sent %d list requests, want 2 pages", n)
+	}
+}
+
+func TestStreamRecordsCanceled(t *testing.T) {
+	api, p := newFakeAPI(t)
+	p.PageSize = 2
+	for i := 1; i <= 3; i++ {
+		api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: fmt.Sprintf("192.0.2.%d", i)})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	recsCh, errCh := p.StreamRecords(ctx, "example.com")
+	<-recsCh
+	cancel()
+
+	// the producer stops without the other records being consumed
+	if err := <-errCh; !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want context.Canceled", err)
+	}
+	for range recsCh {
+		t.Error("got a record after the error")
+	}
+}
+
+func TestSetRecordsReturnsUpdatedRecord(t *testing.T) {
+	api, p := newFakeAPI(t)
+	existing := api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: "192.0.2.1", TTL: 3600})
+
+	results, err := p.SetRecords(context.Background(), "example.com", []libdns.Record{
+		{Type: "A", Name: "www", Value: "192.0.2.1", TTL: 5 * time.Minute},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := api.countRequests(http.MethodPatch, "/dns_records/"+existing.ID); n != 1 {
+		t.Fatalf("sent %d updates, want 1", n)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d records, want 1", len(results))
+	}
+	if got := results[0]; got.ID != existing.ID || got.TTL != 5*time.Minute || got.Name != "www" {
+		t.Errorf("got %+v, want the updated record with a TTL of 5m", got)
+	}
+}
+
+func TestDeleteRecordsTXTCaseSensitive(t *testing.T) {
+	api, p := newFakeAPI(t)
+	api.addRecord("zone1", models.DNSRecord{Type: "TXT", Hostname: "_acme-challenge.example.com", Value: "Token"})
+	lower := api.addRecord("zone1", models.DNSRecord{Type: "TXT", Hostname: "_acme-challenge.example.com", Value: "token"})
+
+	deleted, err := p.DeleteRecords(context.Background(), "example.com", []libdns.Record{
+		{Type: "TXT", Name: "_ACME-Challenge", Value: "Token"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deleted) != 1 || deleted[0].Value != "Token" {
+		t.Errorf("deleted %+v, want only the Token record", deleted)
+	}
+	remaining := api.zoneRecords("zone1")
+	if len(remaining) != 1 || remaining[0].ID != lower.ID {
+		t.Errorf("zone has %+v, want only the token record", remaining)
+	}
+}
+
+func TestSetRecordsIdenticalUpdate(t *testing.T) {
+	api, p := newFakeAPI(t)
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: "192.0.2.1", TTL: 3600})
+	api.addRecord("zone1", models.DNSRecord{Type: "MX", Hostname: "example.com", Value: "mail.example.com", Priority: 10, TTL: 3600})
+
+	results, err := p.SetRecords(context.Background(), "example.com", []libdns.Record{
+		{Type: "A", Name: "www", Value: "192.0.2.1", TTL: time.Hour},
+		{Type: "MX", Name: "@", Value: "Mail.Example.com.", Priority: 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []string{http.MethodPost, http.MethodPatch, http.MethodDelete} {
+		if n := api.countRequests(method, ""); n != 0 {
+			t.Errorf("sent %d %s requests for identical records", n, method)
+		}
+	}
+	if len(results) != 2 || results[0].TTL != time.Hour || results[1].Priority != 10 {
+		t.Errorf("got %+v, want the records of the zone", results)
+	}
+}
+
+func TestEnsureRecordActions(t *testing.T) {
+	api, p := newFakeAPI(t)
+	ctx := context.Background()
+
+	steps := []struct {
+		name   string
+		record libdns.Record
+		want   EnsureAction
+	}{
+		{"create", libdns.Record{Type: "A", Name: "www", Value: "192.0.2.1", TTL: time.Hour}, RecordCreated},
+		{"no-op", libdns.Record{Type: "A", Name: "www", Value: "192.0.2.1", TTL: time.Hour}, RecordUnchanged},
+		{"update", libdns.Record{Type: "A", Name: "www", Value: "192.0.2.2", TTL: time.Hour}, RecordUpdated},
+		{"no-op after update", libdns.Record{Type: "A", Name: "WWW", Value: "192.0.2.2"}, RecordUnchanged},
+	}
+	for _, step := range steps {
+		rec, action, err := p.EnsureRecord(ctx, "example.com", step.record)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if action != step.want {
+			t.Errorf("%s: got action %s, want %s", step.name, action, step.want)
+		}
+		if rec.Value != step.record.Value || rec.ID == "" {
+			t.Errorf("%s: got %+v, want the record of the zone", step.name, rec)
+		}
+	}
+
+	if n := api.countRequests(http.MethodPost, ""); n != 1 {
+		t.Errorf("sent %d creates, want 1", n)
+	}
+	if n := api.countRequests(http.MethodPatch, ""); n != 1 {
+		t.Errorf("sent %d updates, want 1", n)
+	}
+	if records := api.zoneRecords("zone1"); len(records) != 1 || records[0].Value != "192.0.2.2" {
+		t.Errorf("zone has %+v, want the updated record only", records)
+	}
+}
+
+func TestUpsertRecordsRRset(t *testing.T) {
+	api, p := newFakeAPI(t)
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: "192.0.2.1", TTL: 3600})
+	ctx := context.Background()
+
+	records := []libdns.Record{
+		{Type: "A", Name: "www", Value: "192.0.2.1", TTL: time.Hour},
+		{Type: "A", Name: "www", Value: "192.0.2.2", TTL: time.Hour},
+	}
+	steps := []struct {
+		name string
+		want []EnsureAction
+	}{
+		{"add a value", []EnsureAction{RecordUnchanged, RecordCreated}},
+		{"no-op", []EnsureAction{RecordUnchanged, RecordUnchanged}},
+	}
+	for _, step := range steps {
+		results, err := p.UpsertRecords(ctx, "example.com", records)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if len(results) != len(records) {
+			t.Fatalf("%s: got %d results, want %d", step.name, len(results), len(records))
+		}
+		for i, result := range results {
+			if result.Action != step.want[i] {
+				t.Errorf("%s: record %d: got action %s, want %s", step.name, i, result.Action, step.want[i])
+			}
+			if result.Record.Value != records[i].Value || result.Record.ID == "" {
+				t.Errorf("%s: record %d: got %+v, want the record of the zone", step.name, i, result.Record)
+			}
+		}
+	}
+
+	if n := api.countRequests(http.MethodPatch, ""); n != 0 {
+		t.Errorf("sent %d updates, want none", n)
+	}
+	stored := api.zoneRecords("zone1")
+	if len(stored) != 2 || stored[0].Value != "192.0.2.1" || stored[1].Value != "192.0.2.2" {
+		t.Errorf("zone has %+v, want both values", stored)
+	}
+}
+
+func TestGetRecordsFiltered(t *testing.T) {
+	api, p := newFakeAPI(t)
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: "192.0.2.1"})
+	api.addRecord("zone1", models.DNSRecord{Type: "AAAA", Hostname: "www.example.com", Value: "2001:db8::1"})
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "api.example.com", Value: "192.0.2.2"})
+	api.addRecord("zone1", models.DNSRecord{Type: "TXT", Hostname: "example.com", Value: "v=spf1 -all"})
+
+	filters := []RecordFilter{
+		{Name: "www"},
+		{Name: "WWW.example.com.", Type: "a"},
+		{Type: "A"},
+		{Name: "@", Type: "TXT"},
+		{Name: "missing"},
+	}
+	matches, err := p.GetRecordsFiltered(context.Background(), "example.com", filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[RecordFilter][]string{
+		filters[0]: {"A 192.0.2.1", "AAAA 2001:db8::1"},
+		filters[1]: {"A 192.0.2.1"},
+		filters[2]: {"A 192.0.2.1", "A 192.0.2.2"},
+		filters[3]: {"TXT v=spf1 -all"},
+		filters[4]: nil,
+	}
+	got := make(map[RecordFilter][]string)
+	for filter, records := range matches {
+		var values []string
+		for _, rec := range records {
+			values = append(values, rec.Type+" "+rec.Value)
+		}
+		got[filter] = values
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if n := api.countRequests(http.MethodGet, "/dns_records"); n != 1 {
+		t.Errorf("listed the records %d times, want 1", n)
+	}
+}
+
+func TestBatchErrorIndex(t *testing.T) {
+	api, p := newFakeAPI(t)
+	api.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPost {
+			return false
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if !strings.Contains(string(body), "192.0.2.2") {
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			return false
+		}
+		http.Error(w, `{"code":422,"message":"Unprocessable Entity"}`, http.StatusUnprocessableEntity)
+		return true
+	}
+
+	_, err := p.AppendRecords(context.Background(), "example.com", []libdns.Record{
+		{Type: "A", Name: "www", Value: "192.0.2.1"},
+		{Type: "A", Name: "api", Value: "192.0.2.2"},
+		{Type: "A", Name: "mail", Value: "192.0.2.3"},
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "record 1 (A api): ") {
+		t.Fatalf("got error %v, want it about record 1", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("got error %v, want it to wrap the *APIError", err)
+	}
+	if n := len(api.zoneRecords("zone1")); n != 1 {
+		t.Errorf("zone has %d records, want the batch stopped after the failure", n)
+	}
+}
+
+func TestGetRecordsCanonicalValues(t *testing.T) {
+	api, p := newFakeAPI(t)
+	api.addRecord("zone1", models.DNSRecord{Type: "AAAA", Hostname: "www.example.com", Value: "2001:DB8:0:0:0:0:0:1"})
+	api.addRecord("zone1", models.DNSRecord{Type: "CNAME", Hostname: "blog.example.com", Value: "Target.Example.NET."})
+	api.addRecord("zone1", models.DNSRecord{Type: "MX", Hostname: "example.com", Value: " Mail.Example.com. ", Priority: 10})
+	api.addRecord("zone1", models.DNSRecord{Type: "TXT", Hostname: "example.com", Value: `"v=spf1 " "include:_spf.example.net -all"`})
+	api.addRecord("zone1", models.DNSRecord{Type: "TXT", Hostname: "example.com", Value: `"quoted \"value\""`})
+	api.addRecord("zone1", models.DNSRecord{Type: "TXT", Hostname: "example.com", Value: "  Case Kept  "})
+
+	ctx := context.Background()
+	first, err := p.GetRecords(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, rec := range first {
+		got = append(got, rec.Type+" "+rec.Value)
+	}
+	want := []string{
+		"AAAA 2001:db8::1",
+		"CNAME target.example.net",
+		"MX mail.example.com",
+		"TXT v=spf1 include:_spf.example.net -all",
+		`TXT quoted "value"`,
+		"TXT Case Kept",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got values %q, want %q", got, want)
+	}
+
+	second, err := p.GetRecords(ctx, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("got %+v then %+v, want the same records", first, second)
+	}
+}
+
+func TestBeforeMutateRejectsLowTTL(t *testing.T) {
+	api, p := newFakeAPI(t)
+	errLowTTL := errors.New("TTL under 300s is forbidden")
+	var ops []Operation
+	p.BeforeMutate = func(ctx context.Context, op Operation, record libdns.Record) error {
+		ops = append(ops, op)
+		if op == OpCreate && record.TTL < 300*time.Second {
+			return errLowTTL
+		}
+		return nil
+	}
+
+	ctx := context.Background()
+	_, err := p.AppendRecords(ctx, "example.com", []libdns.Record{
+		{Type: "A", Name: "www", Value: "192.0.2.1", TTL: time.Minute},
+	})
+	if !errors.Is(err, errLowTTL) {
+		t.Errorf("got error %v, want the error of the hook", err)
+	}
+	if n := api.countRequests(http.MethodPost, ""); n != 0 {
+		t.Errorf("sent %d create requests, want none", n)
+	}
+
+	if _, err := p.AppendRecords(ctx, "example.com", []libdns.Record{
+		{Type: "A", Name: "www", Value: "192.0.2.1", TTL: time.Hour},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []Operation{OpCreate, OpCreate}; !reflect.DeepEqual(ops, want) {
+		t.Errorf("got operations %v, want %v", ops, want)
+	}
+}
+
+func TestSetRecordsRequiresType(t *testing.T) {
+	api, p := newFakeAPI(t)
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: "192.0.2.1"})
+
+	_, err := p.SetRecords(context.Background(), "example.com", []libdns.Record{
+		{Name: "www", Value: "192.0.2.2"},
+	})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "type" {
+		t.Errorf("got error %v, want a *ValidationError on the type", err)
+	}
+	if n := len(api.requestLog()); n != 0 {
+		t.Errorf("sent %d requests, want none", n)
+	}
+}
+
+func TestSetRecordsMatchesTypeExactly(t *testing.T) {
+	api, p := newFakeAPI(t)
+	aaaa := api.addRecord("zone1", models.DNSRecord{Type: "AAAA", Hostname: "www.example.com", Value: "2001:db8::1"})
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: "192.0.2.1"})
+
+	if _, err := p.SetRecords(context.Background(), "example.com", []libdns.Record{
+		{Type: "A", Name: "www", Value: "192.0.2.2"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	records := api.zoneRecords("zone1")
+	if len(records) != 2 || records[0].ID != aaaa.ID || records[1].Value != "192.0.2.2" {
+		t.Errorf("zone has %+v, want the AAAA record untouched", records)
+	}
+}
+
+func TestDeleteRecordsByIDChecksZoneRecord(t *testing.T) {
+	api, p := newFakeAPI(t)
+	ns := api.addRecord("zone1", models.DNSRecord{Type: "NS", Hostname: "example.com", Value: "dns1.p01.nsone.net"})
+	txt := api.addRecord("zone1", models.DNSRecord{Type: "TXT", Hostname: "www.example.com", Value: "x"})
+
+	// the guards apply to the record with the ID, whatever the rest of
+	// the record passed
+	ctx := context.Background()
+	_, err := p.DeleteRecords(ctx, "example.com", []libdns.Record{{ID: ns.ID, Type: "TXT", Name: "www"}})
+	if !errors.Is(err, ErrApexNSChange) {
+		t.Errorf("got error %v, want ErrApexNSChange", err)
+	}
+	if n := api.countRequests(http.MethodDelete, ""); n != 0 {
+		t.Errorf("sent %d delete requests, want none", n)
+	}
+
+	deleted, err := p.DeleteRecords(ctx, "example.com", []libdns.Record{{ID: txt.ID, Type: "A", Value: "stale"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0].Type != "TXT" || deleted[0].Name != "www" || deleted[0].Value != "x" {
+		t.Errorf("deleted %+v, want the TXT record as it was in the zone", deleted)
+	}
+}
+
+func TestDeleteRecordsDetailedMissing(t *testing.T) {
+	api, p := newFakeAPI(t)
+	rec := api.addRecord("zone1", models.DNSRecord{Type: "TXT", Hostname: "_acme-challenge.example.com", Value: "token"})
+
+	result, err := p.DeleteRecordsDetailed(context.Background(), "example.com", []libdns.Record{
+		{ID: rec.ID},
+		{ID: "gone"},
+		{Type: "TXT", Name: "_acme-challenge", Value: "other"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Deleted) != 1 || result.Deleted[0].ID != rec.ID {
+		t.Errorf("deleted %+v, want the existing record", result.Deleted)
+	}
+	if len(result.Missing) != 2 || result.Missing[0].ID != "gone" || result.Missing[1].Value != "other" {
+		t.Errorf("got missing %+v, want the other records", result.Missing)
+	}
+}
+
+func TestDeleteRecordsZoneDeleted(t *testing.T) {
+	api, p := newFakeAPI(t)
+	rec := api.addRecord("zone1", models.DNSRecord{Type: "TXT", Hostname: "_acme-challenge.example.com", Value: "token"})
+	// the zone is deleted between the lookup and the delete
+	api.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodDelete || r.URL.Path == "/dns_zones/zone1" {
+			http.Error(w, `{"code":404,"message":"Not Found"}`, http.StatusNotFound)
+			return true
+		}
+		return false
+	}
+
+	for _, record := range []libdns.Record{
+		{Type: "TXT", Name: "_acme-challenge", Value: "token"},
+		{ID: rec.ID},
+	} {
+		_, err := p.DeleteRecordsDetailed(context.Background(), "example.com", []libdns.Record{record})
+		if !errors.Is(err, ErrZoneNotFound) {
+			t.Errorf("deleting %+v: got error %v, want ErrZoneNotFound", record, err)
+		}
+	}
+}
+
+func TestDeleteRecordsConcurrentlyDeleted(t *testing.T) {
+	api, p := newFakeAPI(t)
+	rec := api.addRecord("zone1", models.DNSRecord{Type: "TXT", Hostname: "_acme-challenge.example.com", Value: "token"})
+	// another cleanup deletes the record between the lookup and the delete
+	api.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodDelete {
+			http.Error(w, `{"code":404,"message":"Not Found"}`, http.StatusNotFound)
+			return true
+		}
+		return false
+	}
+
+	result, err := p.DeleteRecordsDetailed(context.Background(), "example.com", []libdns.Record{
+		{Type: "TXT", Name: "_acme-challenge", Value: "token"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Deleted) != 0 || len(result.Missing) != 1 || result.Missing[0].ID != rec.ID {
+		t.Errorf("got %+v, want the record reported missing", result)
+	}
+}
@@ -0,0 +1,316 @@
+package netlify
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces out requests so that no more than one request is sent
+// every interval
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perSecond float64) *rateLimiter {
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// wait blocks until the next request is allowed to be sent. It returns the
+// context error if ctx is done before that
+func (l *rateLimiter) wait(ctx context.Context, clock Clock) error {
+	l.mu.Lock()
+	now := clock.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	select {
+	case <-clock.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// tokenHash identifies an access token, so that state can be kept per token
+// without keeping the token itself
+type tokenHash [sha256.Size]byte
+
+// requestToken returns the hash of the access token req is authenticated
+// with
+func requestToken(req *http.Request) tokenHash {
+	return hashToken(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "))
+}
+
+// hashToken returns the hash of the access token
+func hashToken(token string) tokenHash {
+	return sha256.Sum256([]byte(token))
+}
+
+// sharedLimits holds the rate limits shared by the providers with
+// ShareRateLimit set, by access token
+var (
+	sharedLimits   = make(map[tokenHash]*sharedLimit)
+	sharedLimitsMu sync.Mutex
+)
+
+// sharedLimit is the rate limit state shared by the providers using the same
+// access token
+type sharedLimit struct {
+	limiter *rateLimiter
+	tracker rateTracker
+}
+
+// sharedLimit returns the rate limit state shared by the providers using the
+// access token, creating it if needed
+func (p *Provider) sharedLimit(token tokenHash) *sharedLimit {
+	sharedLimitsMu.Lock()
+	defer sharedLimitsMu.Unlock()
+	shared := sharedLimits[token]
+	if shared == nil {
+		shared = &sharedLimit{}
+		sharedLimits[token] = shared
+	}
+	if p.RateLimit > 0 && shared.limiter == nil {
+		shared.limiter = newRateLimiter(p.RateLimit)
+	}
+	return shared
+}
+
+// tracker returns the rateTracker following the rate limit of the access
+// token, as each Netlify account has its own
+func (p *Provider) tracker(token tokenHash) *rateTracker {
+	if p.ShareRateLimit {
+		return &p.sharedLimit(token).tracker
+	}
+
+	p.limitersMu.Lock()
+	defer p.limitersMu.Unlock()
+	if p.rateTrackers == nil {
+		p.rateTrackers = make(map[tokenHash]*rateTracker)
+	}
+	tracker := p.rateTrackers[token]
+	if tracker == nil {
+		tracker = &rateTracker{}
+		p.rateTrackers[token] = tracker
+	}
+	return tracker
+}
+
+// waitRateLimit waits for the limiter of the access token and, when zoneID
+// is set, for the limiter of that zone
+func (p *Provider) waitRateLimit(ctx context.Context, token tokenHash, zoneID string) error {
+	var global *rateLimiter
+	if p.ShareRateLimit {
+		global = p.sharedLimit(token).limiter
+	}
+
+	p.limitersMu.Lock()
+	if !p.ShareRateLimit && p.RateLimit > 0 {
+		if p.limiters == nil {
+			p.limiters = make(map[tokenHash]*rateLimiter)
+		}
+		global = p.limiters[token]
+		if global == nil {
+			global = newRateLimiter(p.RateLimit)
+			p.limiters[token] = global
+		}
+	}
+
+	var zone *rateLimiter
+	if p.ZoneRateLimit > 0 && zoneID != "" {
+		if p.zoneLimiters == nil {
+			p.zoneLimiters = make(map[string]*rateLimiter)
+		}
+		zone = p.zoneLimiters[zoneID]
+		if zone == nil {
+			zone = newRateLimiter(p.ZoneRateLimit)
+			p.zoneLimiters[zoneID] = zone
+		}
+	}
+	p.limitersMu.Unlock()
+
+	if global != nil {
+		if err := global.wait(ctx, p.clock()); err != nil {
+			return err
+		}
+	}
+	if zone != nil {
+		if err := zone.wait(ctx, p.clock()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// acquireRequestSlot blocks until fewer than MaxConcurrentRequests requests
+// are in flight. It returns a function releasing the slot, or the context
+// error if ctx is done before a slot is free
+func (p *Provider) acquireRequestSlot(ctx context.Context) (func(), error) {
+	if p.MaxConcurrentRequests <= 0 {
+		return func() {}, nil
+	}
+
+	p.limitersMu.Lock()
+	if p.requestSlots == nil {
+		p.requestSlots = make(chan struct{}, p.MaxConcurrentRequests)
+	}
+	slots := p.requestSlots
+	p.limitersMu.Unlock()
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// writeQueue serializes the requests changing records, spacing them out by
+// at least MinWriteInterval
+type writeQueue struct {
+	slot      chan struct{}
+	lastWrite time.Time
+}
+
+// acquireWriteSlot blocks until no other create, update or delete request is
+// in flight and MinWriteInterval has elapsed since the last one. It returns a
+// function releasing the slot, or the context error if ctx is done before
+// that. Requests other than writes don't wait
+func (p *Provider) acquireWriteSlot(ctx context.Context, method string) (func(), error) {
+	if p.MinWriteInterval <= 0 || method == http.MethodGet {
+		return func() {}, nil
+	}
+
+	p.limitersMu.Lock()
+	if p.writeQueue.slot == nil {
+		p.writeQueue.slot = make(chan struct{}, 1)
+	}
+	slot := p.writeQueue.slot
+	p.limitersMu.Unlock()
+
+	select {
+	case slot <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	release := func() {
+		// only the holder of the slot touches lastWrite
+		p.writeQueue.lastWrite = p.clock().Now()
+		<-slot
+	}
+
+	if !p.writeQueue.lastWrite.IsZero() {
+		delay := p.writeQueue.lastWrite.Add(p.MinWriteInterval).Sub(p.clock().Now())
+		if delay > 0 {
+			select {
+			case <-p.clock().After(delay):
+			case <-ctx.Done():
+				<-slot
+				return nil, ctx.Err()
+			}
+		}
+	}
+	return release, nil
+}
+
+// retryAfter returns how long to wait before sending another request, as
+// told by the Retry-After or X-RateLimit-Reset headers of a response. It
+// returns zero if neither header is usable
+func retryAfter(header http.Header, now time.Time) time.Duration {
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if date, err := http.ParseTime(value); err == nil && date.After(now) {
+			return date.Sub(now)
+		}
+	}
+	if value := header.Get("X-RateLimit-Reset"); value != "" {
+		if reset, err := strconv.ParseInt(value, 10, 64); err == nil {
+			if date := time.Unix(reset, 0); date.After(now) {
+				return date.Sub(now)
+			}
+		}
+	}
+	return 0
+}
+
+// rateLowWater is the number of remaining requests below which requests are
+// spread until the rate limit resets
+const rateLowWater = 10
+
+// rateTracker follows the rate limit reported by Netlify in the headers of
+// its responses
+type rateTracker struct {
+	mu        sync.Mutex
+	known     bool
+	remaining int
+	reset     time.Time
+}
+
+// update records the rate limit state from the headers of a response
+func (t *rateTracker) update(header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.known = true
+	t.remaining = remaining
+	t.reset = time.Unix(reset, 0)
+}
+
+// delay returns how long to wait before the next request so that the
+// remaining requests are spread until the rate limit resets
+func (t *rateTracker) delay(now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.known || !now.Before(t.reset) || t.remaining >= rateLowWater {
+		return 0
+	}
+	untilReset := t.reset.Sub(now)
+	if t.remaining <= 0 {
+		return untilReset
+	}
+	t.remaining--
+	return untilReset / time.Duration(t.remaining+2)
+}
+
+// waitRateTracker blocks until the next request can be sent without hitting
+// the rate limit reported by Netlify for the access token. It returns the
+// context error if ctx is done before that
+func (p *Provider) waitRateTracker(ctx context.Context, token tokenHash) error {
+	delay := p.tracker(token).delay(p.clock().Now())
+	if delay <= 0 {
+		return nil
+	}
+
+	select {
+	case <-p.clock().After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,190 @@
+package netlify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/netlify/open-api/v2/go/models"
+)
+
+func TestZoneRateLimitsAreIndependent(t *testing.T) {
+	api, p := newFakeAPI(t)
+	api.addZone("zone2", "example.org")
+	clock := newFakeClock()
+	p.Clock = clock
+	p.ZoneRateLimit = 1
+
+	ctx := context.Background()
+	for _, zone := range []string{"example.com", "example.org", "example.com"} {
+		if _, err := p.GetRecords(ctx, zone); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// only the second listing of example.com waits for its zone; the
+	// listing of example.org in between doesn't delay it further
+	if got, want := clock.waited(), []time.Duration{time.Second}; !reflect.DeepEqual(got, want) {
+		t.Errorf("waited %v, want %v", got, want)
+	}
+}
+
+func TestGlobalRateLimitAppliesAcrossZones(t *testing.T) {
+	api, p := newFakeAPI(t)
+	api.addZone("zone2", "example.org")
+	clock := newFakeClock()
+	p.Clock = clock
+	p.RateLimit = 2
+
+	ctx := context.Background()
+	for _, zone := range []string{"example.com", "example.org"} {
+		if _, err := p.GetRecords(ctx, zone); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// four requests: a zone lookup and a listing for each zone
+	want := []time.Duration{500 * time.Millisecond, 500 * time.Millisecond, 500 * time.Millisecond}
+	if got := clock.waited(); !reflect.DeepEqual(got, want) {
+		t.Errorf("waited %v, want %v", got, want)
+	}
+}
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	api, p := newFakeAPI(t)
+	p.MaxConcurrentRequests = 2
+	rec := api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "www.example.com", Value: "192.0.2.1"})
+
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	api.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		return false
+	}
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := p.GetRecordByID(ctx, "example.com", rec.ID)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if maxInFlight > 2 {
+		t.Errorf("got %d requests in flight, want at most 2", maxInFlight)
+	}
+}
+
+func TestMaxConcurrentRequestsContext(t *testing.T) {
+	_, p := newFakeAPI(t)
+	p.MaxConcurrentRequests = 1
+
+	release, err := p.acquireRequestSlot(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.acquireRequestSlot(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want context.Canceled while waiting for a slot", err)
+	}
+}
+
+func TestRateLimitErrorRetryAfter(t *testing.T) {
+	clock := newFakeClock()
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"seconds", http.Header{"Retry-After": {"30"}}, 30 * time.Second},
+		{"date", http.Header{"Retry-After": {clock.Now().Add(time.Minute).Format(http.TimeFormat)}}, time.Minute},
+		{"reset", http.Header{"X-Ratelimit-Reset": {strconv.FormatInt(clock.Now().Add(90*time.Second).Unix(), 10)}}, 90 * time.Second},
+		{"none", http.Header{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, p := newFakeAPI(t)
+			p.Clock = clock
+			p.RetryPolicy = retryFunc(func(int, error) (time.Duration, bool) { return 0, false })
+			api.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+				for key, values := range tt.header {
+					w.Header()[key] = values
+				}
+				http.Error(w, `{"code":429,"message":"Too Many Requests"}`, http.StatusTooManyRequests)
+				return true
+			}
+
+			_, err := p.GetRecords(context.Background(), "example.com")
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+				t.Fatalf("got error %v, want an *APIError with status 429", err)
+			}
+			if apiErr.RetryAfter != tt.want {
+				t.Errorf("got RetryAfter %s, want %s", apiErr.RetryAfter, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitsPerToken(t *testing.T) {
+	clock := newFakeClock()
+	p := &Provider{Clock: clock, RateLimit: 1}
+	token := func(value string) tokenHash {
+		req, _ := http.NewRequest(http.MethodGet, "https://api.netlify.com/api/v1/dns_zones", nil)
+		req.Header.Set("Authorization", "Bearer "+value)
+		return requestToken(req)
+	}
+	a, b := token("nfp_tokena"), token("nfp_tokenb")
+
+	ctx := context.Background()
+	for _, tok := range []tokenHash{a, b, a} {
+		if err := p.waitRateLimit(ctx, tok, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// only the second request with token a waits; the request with token b
+	// counts against its own limit
+	if got, want := clock.waited(), []time.Duration{time.Second}; !reflect.DeepEqual(got, want) {
+		t.Errorf("waited %v, want %v", got, want)
+	}
+
+	// an exhausted rate limit reported for token a doesn't delay token b
+	reset := strconv.FormatInt(clock.Now().Add(time.Minute).Unix(), 10)
+	p.tracker(a).update(http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {reset}})
+	if delay := p.tracker(a).delay(clock.Now()); delay <= 0 {
+		t.Errorf("got no delay for token a, want it to wait for the reset")
+	}
+	if delay := p.tracker(b).delay(clock.Now()); delay != 0 {
+		t.Errorf("got a delay of %s for token b, want none", delay)
+	}
+}
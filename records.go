@@ -0,0 +1,196 @@
+package netlify
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// Record is a DNS record of a specific type, as modeled by the typed records
+// of libdns v1: Address, TXT and SRV, or RR for the other types. TypedRecord
+// and FlatRecord convert between it and the flat libdns.Record.
+type Record interface {
+	// RR returns the record in its generic form
+	RR() RR
+}
+
+// RR is a DNS record of any type, with its data in the zone file format, such
+// as "10 mail.example.com." for an MX record.
+type RR struct {
+	Name string
+	TTL  time.Duration
+	Type string
+	Data string
+}
+
+// RR returns r itself.
+func (r RR) RR() RR {
+	return r
+}
+
+// Parse returns the record as an Address, TXT or SRV for these types, and r
+// itself for the other types. It returns a *ValidationError if the data isn't
+// valid for the type.
+func (r RR) Parse() (Record, error) {
+	switch recType := strings.ToUpper(r.Type); recType {
+	case "A", "AAAA":
+		family := "IPv6"
+		if recType == "A" {
+			family = "IPv4"
+		}
+		ip, err := netip.ParseAddr(strings.TrimSpace(r.Data))
+		if err != nil || ip.Is4() != (recType == "A") {
+			return nil, &ValidationError{Type: recType, Field: "value", Reason: fmt.Sprintf("%q for %s is not an %s address", r.Data, r.Name, family)}
+		}
+		return Address{Name: r.Name, TTL: r.TTL, IP: ip}, nil
+	case "TXT":
+		return TXT{Name: r.Name, TTL: r.TTL, Text: r.Data}, nil
+	case "SRV":
+		if err := validateSRVName(r.Name); err != nil {
+			return nil, err
+		}
+		srv, err := parseSRV(libdns.Record{Type: "SRV", Name: r.Name, Value: r.Data})
+		if err != nil {
+			return nil, err
+		}
+		labels := strings.SplitN(r.Name, ".", 3)
+		name := ""
+		if len(labels) == 3 {
+			name = labels[2]
+		}
+		return SRV{
+			Service:   strings.TrimPrefix(labels[0], "_"),
+			Transport: strings.TrimPrefix(labels[1], "_"),
+			Name:      name,
+			TTL:       r.TTL,
+			Priority:  uint16(srv.Priority),
+			Weight:    uint16(srv.Weight),
+			Port:      uint16(srv.Port),
+			Target:    srv.Target,
+		}, nil
+	}
+	return r, nil
+}
+
+// Address is an A record, or an AAAA record if IP is an IPv6 address.
+type Address struct {
+	Name string
+	TTL  time.Duration
+	IP   netip.Addr
+}
+
+// RR returns the address as an A or AAAA record.
+func (a Address) RR() RR {
+	recType := "AAAA"
+	if a.IP.Is4() {
+		recType = "A"
+	}
+	return RR{Name: a.Name, TTL: a.TTL, Type: recType, Data: a.IP.String()}
+}
+
+// TXT is a TXT record. Text is the unquoted value, which is split in strings
+// of up to 255 bytes when sent to Netlify.
+type TXT struct {
+	Name string
+	TTL  time.Duration
+	Text string
+}
+
+// RR returns the TXT record in its generic form.
+func (t TXT) RR() RR {
+	return RR{Name: t.Name, TTL: t.TTL, Type: "TXT", Data: t.Text}
+}
+
+// SRV is a SRV record. Service and Transport are given without their leading
+// underscore, such as "sip" and "tcp", and Name is the name the service is
+// offered under, empty for the zone apex.
+type SRV struct {
+	Service   string
+	Transport string
+	Name      string
+	TTL       time.Duration
+	Priority  uint16
+	Weight    uint16
+	Port      uint16
+	Target    string
+}
+
+// RR returns the SRV record in its generic form, named
+// _service._transport.name.
+func (s SRV) RR() RR {
+	name := "_" + s.Service + "._" + s.Transport
+	if s.Name != "" && s.Name != "@" {
+		name += "." + s.Name
+	}
+	return RR{
+		Name: name,
+		TTL:  s.TTL,
+		Type: "SRV",
+		Data: fmt.Sprintf("%d %d %d %s", s.Priority, s.Weight, s.Port, s.Target),
+	}
+}
+
+// typedRecords converts the flat records to typed records. A record whose
+// value isn't valid for its type is returned as an RR, so that listing a zone
+// doesn't fail on a record Netlify accepted
+func typedRecords(records []libdns.Record) []Record {
+	typed := make([]Record, 0, len(records))
+	for _, rec := range records {
+		r, err := TypedRecord(rec)
+		if err != nil {
+			r = RR{Name: rec.Name, TTL: rec.TTL, Type: rec.Type, Data: rec.Value}
+		}
+		typed = append(typed, r)
+	}
+	return typed
+}
+
+// flatRecords converts the typed records to flat records
+func flatRecords(records []Record) []libdns.Record {
+	flat := make([]libdns.Record, 0, len(records))
+	for _, rec := range records {
+		flat = append(flat, FlatRecord(rec))
+	}
+	return flat
+}
+
+// GetTypedRecords lists all the records in the zone as GetRecords does, as
+// typed records.
+func (p *Provider) GetTypedRecords(ctx context.Context, zone string) ([]Record, error) {
+	records, err := p.GetRecords(ctx, zone)
+	return typedRecords(records), err
+}
+
+// AppendTypedRecords adds typed records to the zone as AppendRecords does. It
+// returns the records that were added.
+func (p *Provider) AppendTypedRecords(ctx context.Context, zone string, records []Record) ([]Record, error) {
+	created, err := p.AppendRecords(ctx, zone, flatRecords(records))
+	if err != nil {
+		return nil, err
+	}
+	return typedRecords(created), nil
+}
+
+// SetTypedRecords sets typed records in the zone as SetRecords does. It
+// returns the records as set in the zone.
+func (p *Provider) SetTypedRecords(ctx context.Context, zone string, records []Record) ([]Record, error) {
+	set, err := p.SetRecords(ctx, zone, flatRecords(records))
+	if err != nil {
+		return nil, err
+	}
+	return typedRecords(set), nil
+}
+
+// DeleteTypedRecords deletes typed records from the zone as DeleteRecords
+// does. It returns the records that were deleted.
+func (p *Provider) DeleteTypedRecords(ctx context.Context, zone string, records []Record) ([]Record, error) {
+	deleted, err := p.DeleteRecords(ctx, zone, flatRecords(records))
+	if err != nil {
+		return nil, err
+	}
+	return typedRecords(deleted), nil
+}
@@ -0,0 +1,211 @@
+package netlify
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// defaultRetryBackoff is the delay before the first retry when RetryBackoff
+// isn't set
+const defaultRetryBackoff = 500 * time.Millisecond
+
+// defaultMaxRetryBackoff caps the delay between retries when the maximum of
+// ExponentialBackoff isn't set
+const defaultMaxRetryBackoff = 30 * time.Second
+
+// retryCheck tells whether a failed request was applied anyway, in which
+// case it returns the body to use as response instead of retrying
+type retryCheck func() ([]byte, bool)
+
+type retryCheckKey struct{}
+
+// withRetryCheck returns a copy of ctx making the requests sent with it call
+// check before each retry
+func withRetryCheck(ctx context.Context, check retryCheck) context.Context {
+	return context.WithValue(ctx, retryCheckKey{}, check)
+}
+
+// newIdempotencyKey returns a random key identifying a logical request
+// across its retries
+func newIdempotencyKey() string {
+	var b [16]byte
+	cryptorand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// retryable reports whether a request which failed with err may succeed if
+// sent again
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var netErr *NetworkError
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+	}
+	return false
+}
+
+// RetryPolicy decides whether and when a failed request is sent again.
+type RetryPolicy interface {
+	// Retry is called after the given failed attempt of a request,
+	// starting at 1, with the error of the attempt. It returns how long
+	// to wait before sending the request again, and false if it mustn't
+	// be sent again
+	Retry(attempt int, err error) (time.Duration, bool)
+}
+
+// ExponentialBackoff is the default RetryPolicy. It retries the requests
+// failing with a network error, a 5xx status or rate limited up to MaxRetries
+// times. Before each retry it waits a random delay between zero and Base
+// doubled for each retry, capped to Max ("full jitter"). Rate limited requests
+// wait as long as Netlify told them to instead.
+type ExponentialBackoff struct {
+	MaxRetries int
+	Base       time.Duration
+	Max        time.Duration
+}
+
+// Retry implements RetryPolicy.
+func (b ExponentialBackoff) Retry(attempt int, err error) (time.Duration, bool) {
+	if attempt > b.MaxRetries || !retryable(err) {
+		return 0, false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter, true
+	}
+
+	ceiling := b.ceiling(attempt)
+	if ceiling <= 0 {
+		return 0, true
+	}
+	return time.Duration(rand.Int63n(int64(ceiling))), true
+}
+
+// ceiling returns the maximum delay before the retry following the given
+// attempt
+func (b ExponentialBackoff) ceiling(attempt int) time.Duration {
+	base := b.Base
+	if base <= 0 {
+		base = defaultRetryBackoff
+	}
+	max := b.Max
+	if max <= 0 {
+		max = defaultMaxRetryBackoff
+	}
+
+	ceiling := base
+	for i := 1; i < attempt && ceiling < max; i++ {
+		ceiling *= 2
+	}
+	if ceiling > max {
+		ceiling = max
+	}
+	return ceiling
+}
+
+// retryPolicy returns the RetryPolicy of the provider, defaulting to an
+// ExponentialBackoff configured by MaxRetries and RetryBackoff which always
+// retries rate limited requests
+func (p *Provider) retryPolicy() RetryPolicy {
+	if p.RetryPolicy != nil {
+		return p.RetryPolicy
+	}
+	return rateLimitRetry{ExponentialBackoff{MaxRetries: p.MaxRetries, Base: p.RetryBackoff}}
+}
+
+// maxRateLimitRetries is the number of times a rate limited request is
+// retried by default, whatever MaxRetries
+const maxRateLimitRetries = 10
+
+// rateLimitRetry retries the rate limited requests its RetryPolicy gives up
+// on, up to maxRateLimitRetries times, so that a burst of requests doesn't
+// fail when retries are disabled. The retries stop at the deadline of the
+// context like any other
+type rateLimitRetry struct {
+	RetryPolicy
+}
+
+// Retry implements RetryPolicy.
+func (r rateLimitRetry) Retry(attempt int, err error) (time.Duration, bool) {
+	if delay, ok := r.RetryPolicy.Retry(attempt, err); ok {
+		return delay, true
+	}
+
+	var apiErr *APIError
+	if attempt > maxRateLimitRetries || !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if apiErr.RetryAfter > 0 {
+		return apiErr.RetryAfter, true
+	}
+	return ExponentialBackoff{}.ceiling(attempt), true
+}
+
+// sendWithRetries sends req, sending it again as long as the retry policy
+// allows it when it fails. It returns the response body, or the error of the
+// last attempt
+func (p *Provider) sendWithRetries(req *http.Request, zoneID string) ([]byte, error) {
+	ctx := req.Context()
+	policy := p.retryPolicy()
+	for retry := 0; ; retry++ {
+		if retry > 0 {
+			if check, ok := ctx.Value(retryCheckKey{}).(retryCheck); ok {
+				if body, done := check(); done {
+					p.logger().Debug("request already applied, not retrying",
+						"method", req.Method, "url", req.URL.String(),
+						"idempotency_key", req.Header.Get("Idempotency-Key"))
+					return body, nil
+				}
+			}
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				req.Body = body
+			}
+		}
+
+		if err := p.allowRequest(); err != nil {
+			return nil, err
+		}
+
+		body, err := p.sendRequest(req, zoneID)
+		p.recordResult(err)
+		if err == nil {
+			return body, nil
+		}
+		delay, ok := policy.Retry(retry+1, err)
+		if !ok {
+			return nil, err
+		}
+
+		// don't wait for a retry which can't be sent before the deadline
+		if deadline, ok := ctx.Deadline(); ok && p.clock().Now().Add(delay).After(deadline) {
+			return nil, &causedError{msg: "no time left to retry before the deadline", cause: context.DeadlineExceeded, err: err}
+		}
+
+		select {
+		case <-p.clock().After(delay):
+		case <-ctx.Done():
+			return nil, &causedError{cause: ctx.Err(), err: err}
+		}
+	}
+}
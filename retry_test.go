@@ -0,0 +1,193 @@
+package netlify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// retryFunc is a RetryPolicy calling the function
+type retryFunc func(attempt int, err error) (time.Duration, bool)
+
+func (f retryFunc) Retry(attempt int, err error) (time.Duration, bool) {
+	return f(attempt, err)
+}
+
+// failRecordLists makes the first len(statuses) record lists of api fail
+// with the given statuses, with the matching Retry-After header if set
+func failRecordLists(api *fakeAPI, statuses []int, retryAfter []string) {
+	failures := 0
+	api.intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if !strings.HasSuffix(r.URL.Path, "/dns_records") || failures >= len(statuses) {
+			return false
+		}
+		if failures < len(retryAfter) && retryAfter[failures] != "" {
+			w.Header().Set("Retry-After", retryAfter[failures])
+		}
+		http.Error(w, `{"code":`+strconv.Itoa(statuses[failures])+`}`, statuses[failures])
+		failures++
+		return true
+	}
+}
+
+func TestExponentialBackoffCeiling(t *testing.T) {
+	b := ExponentialBackoff{Base: 100 * time.Millisecond, Max: time.Second}
+
+	want := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for i, ceiling := range want {
+		if got := b.ceiling(i + 1); got != ceiling {
+			t.Errorf("attempt %d: got ceiling %s, want %s", i+1, got, ceiling)
+		}
+	}
+}
+
+func TestExponentialBackoffJitter(t *testing.T) {
+	b := ExponentialBackoff{MaxRetries: 4, Base: 100 * time.Millisecond, Max: time.Second}
+	err := &APIError{StatusCode: http.StatusBadGateway}
+
+	for attempt := 1; attempt <= 4; attempt++ {
+		for i := 0; i < 100; i++ {
+			delay, ok := b.Retry(attempt, err)
+			if !ok || delay < 0 || delay >= b.ceiling(attempt) {
+				t.Fatalf("attempt %d: got %s, %t, want a delay below %s", attempt, delay, ok, b.ceiling(attempt))
+			}
+		}
+	}
+	if _, ok := b.Retry(5, err); ok {
+		t.Error("retried past MaxRetries")
+	}
+}
+
+func TestRetryBackoffSequence(t *testing.T) {
+	api, p := newFakeAPI(t)
+	clock := newFakeClock()
+	p.Clock = clock
+	p.RetryPolicy = retryFunc(func(attempt int, err error) (time.Duration, bool) {
+		return time.Duration(attempt) * 100 * time.Millisecond, attempt <= 3
+	})
+	failRecordLists(api, []int{500, 502, 503}, nil)
+
+	if _, err := p.GetRecords(context.Background(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond}
+	if got := clock.waited(); !reflect.DeepEqual(got, want) {
+		t.Errorf("waited %v, want %v", got, want)
+	}
+}
+
+func TestRetryAfterSequence(t *testing.T) {
+	api, p := newFakeAPI(t)
+	clock := newFakeClock()
+	p.Clock = clock
+	p.MaxRetries = 3
+	failRecordLists(api, []int{429, 429, 429}, []string{"1", "3", "2"})
+
+	if _, err := p.GetRecords(context.Background(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []time.Duration{time.Second, 3 * time.Second, 2 * time.Second}
+	if got := clock.waited(); !reflect.DeepEqual(got, want) {
+		t.Errorf("waited %v, want %v", got, want)
+	}
+}
+
+func TestRetryStopsAtMaxRetries(t *testing.T) {
+	api, p := newFakeAPI(t)
+	clock := newFakeClock()
+	p.Clock = clock
+	p.MaxRetries = 2
+	failRecordLists(api, []int{500, 500, 500, 500}, nil)
+
+	if _, err := p.GetRecords(context.Background(), "example.com"); err == nil {
+		t.Fatal("got no error")
+	}
+	if n := api.countRequests(http.MethodGet, "/dns_records"); n != 3 {
+		t.Errorf("sent %d requests, want 3", n)
+	}
+	if n := len(clock.waited()); n != 2 {
+		t.Errorf("waited %d times, want 2", n)
+	}
+}
+
+func TestRateLimitedRetriedByDefault(t *testing.T) {
+	api, p := newFakeAPI(t)
+	clock := newFakeClock()
+	p.Clock = clock
+	failRecordLists(api, []int{429, 429}, []string{"2", ""})
+
+	if _, err := p.GetRecords(context.Background(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	waits := clock.waited()
+	if len(waits) != 2 || waits[0] != 2*time.Second || waits[1] <= 0 {
+		t.Errorf("waited %v, want 2s then a backoff", waits)
+	}
+}
+
+func TestRateLimitedRetriesBounded(t *testing.T) {
+	api, p := newFakeAPI(t)
+	p.Clock = newFakeClock()
+	statuses := make([]int, 20)
+	for i := range statuses {
+		statuses[i] = http.StatusTooManyRequests
+	}
+	failRecordLists(api, statuses, nil)
+
+	_, err := p.GetRecords(context.Background(), "example.com")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("got error %v, want an *APIError with status 429", err)
+	}
+	if n := api.countRequests(http.MethodGet, "/dns_records"); n != maxRateLimitRetries+1 {
+		t.Errorf("sent %d requests, want %d", n, maxRateLimitRetries+1)
+	}
+}
+
+func TestServerErrorNotRetriedByDefault(t *testing.T) {
+	api, p := newFakeAPI(t)
+	p.Clock = newFakeClock()
+	failRecordLists(api, []int{500}, nil)
+
+	if _, err := p.GetRecords(context.Background(), "example.com"); err == nil {
+		t.Fatal("got no error")
+	}
+	if n := api.countRequests(http.MethodGet, "/dns_records"); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+}
+
+func TestRateLimitedRetryBoundedByDeadline(t *testing.T) {
+	api, p := newFakeAPI(t)
+	failRecordLists(api, []int{429}, []string{"30"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := p.GetRecords(ctx, "example.com")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want context.DeadlineExceeded", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != 30*time.Second {
+		t.Errorf("got error %v, want it to hold the rate limit error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("returned after %s, want it not to wait for a retry past the deadline", elapsed)
+	}
+}
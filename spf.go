@@ -0,0 +1,55 @@
+package netlify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/libdns/libdns"
+)
+
+// MirrorSPFAsTXT creates a TXT record with the same name and value for each
+// SPF record of the zone which has none, as resolvers ignore the legacy SPF
+// type. The SPF records are left in place. It returns the TXT records that
+// were created.
+func (p *Provider) MirrorSPFAsTXT(ctx context.Context, zone string) ([]libdns.Record, error) {
+	ctx, cancel := p.startOperation(ctx, zone)
+	defer cancel()
+
+	zoneInfo, err := p.getWritableZoneInfo(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+	records, err := p.listDNSRecords(ctx, zoneInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	// the TXT records already mirroring an SPF record
+	txts := make(map[string]bool)
+	for _, rec := range records {
+		if strings.EqualFold(rec.Type, "TXT") {
+			txts[normalizeName(rec.Hostname, "")+" "+normalizeValue("TXT", rec.Value)] = true
+		}
+	}
+
+	var created []libdns.Record
+	for _, rec := range records {
+		if !strings.EqualFold(rec.Type, "SPF") {
+			continue
+		}
+		if txts[normalizeName(rec.Hostname, "")+" "+normalizeValue("TXT", rec.Value)] {
+			continue
+		}
+
+		txt := rec.libdnsRecord(zone)
+		txt.ID = ""
+		txt.Type = "TXT"
+		result, err := p.createRecord(ctx, zoneInfo, txt)
+		if err != nil {
+			return created, fmt.Errorf("mirroring SPF record %s: %w", txt.Name, err)
+		}
+		created = append(created, result.libdnsRecord(zone))
+	}
+	return created, nil
+}
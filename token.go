@@ -0,0 +1,275 @@
+package netlify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenSource supplies the access token used to authenticate with Netlify's
+// API. It is consulted before each request, so that rotated tokens are used
+// without recreating the provider.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc is an adapter to use a function as a TokenSource.
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+// Token calls f(ctx).
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// tokenEnvVars are the environment variables the access token is read from
+// when PersonnalAccessToken is empty, in order of precedence
+var tokenEnvVars = []string{"NETLIFY_AUTH_TOKEN", "NETLIFY_TOKEN"}
+
+// accessToken returns the access token of the provider, falling back to the
+// environment when PersonnalAccessToken is empty. It returns an empty string
+// if there is none
+func (p *Provider) accessToken() string {
+	if p.PersonnalAccessToken != "" {
+		return p.PersonnalAccessToken
+	}
+	for _, name := range tokenEnvVars {
+		if token := os.Getenv(name); token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
+// tokenFile holds the token last read from TokenFile
+type tokenFile struct {
+	mu      sync.Mutex
+	path    string
+	token   string
+	modTime time.Time
+	size    int64
+}
+
+// fileToken returns the token in TokenFile, reading the file again if it
+// changed since it was last read or if force is set
+func (p *Provider) fileToken(force bool) (string, error) {
+	f := &p.tokenFile
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(p.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("reading token file: %w", err)
+	}
+	if !force && f.path == p.TokenFile && f.token != "" &&
+		info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.token, nil
+	}
+
+	data, err := ioutil.ReadFile(p.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("reading token file: %w", err)
+	}
+	f.path = p.TokenFile
+	f.token = strings.TrimSpace(string(data))
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	return f.token, nil
+}
+
+// usesTokenFile reports whether the token is read from TokenFile
+func (p *Provider) usesTokenFile() bool {
+	return p.TokenSource == nil && p.PersonnalAccessToken == "" && p.TokenFile != ""
+}
+
+// zoneToken returns the token for the zone of the operation ctx belongs to,
+// from TokenResolver or ZoneTokens. It returns false if neither has one
+func (p *Provider) zoneToken(ctx context.Context) (string, bool, error) {
+	zone, _ := ctx.Value(zoneNameKey{}).(string)
+	if zone == "" {
+		return "", false, nil
+	}
+
+	if p.TokenResolver != nil {
+		token, err := p.TokenResolver(ctx, zone)
+		if err != nil {
+			return "", false, fmt.Errorf("resolving access token for %s: %w", zone, err)
+		}
+		if token != "" {
+			return token, true, nil
+		}
+	}
+
+	// the longest matching suffix wins
+	name := normalizeName(zone, "")
+	var token, match string
+	for suffix, t := range p.ZoneTokens {
+		suffix = normalizeName(suffix, "")
+		if name != suffix && !strings.HasSuffix(name, "."+suffix) {
+			continue
+		}
+		if token == "" || len(suffix) > len(match) {
+			token, match = t, suffix
+		}
+	}
+	return token, token != "", nil
+}
+
+// token returns the access token to authenticate a request with, in order
+// from WithToken, the zone tokens, TokenSource, PersonnalAccessToken, TokenFile
+// and the environment
+func (p *Provider) token(ctx context.Context) (string, error) {
+	if token, ok := ctx.Value(tokenKey{}).(string); ok && token != "" {
+		return token, nil
+	}
+	if token, ok, err := p.zoneToken(ctx); err != nil || ok {
+		return token, err
+	}
+	if p.usesTokenFile() {
+		return p.fileToken(false)
+	}
+	if p.TokenSource == nil {
+		return p.accessToken(), nil
+	}
+	token, err := p.TokenSource.Token(ctx)
+	if err != nil {
+		return "", fmt.Errorf("getting access token: %w", err)
+	}
+	return token, nil
+}
+
+// refreshToken is called when Netlify rejected the token stale. It returns a
+// different token to try again with, and false if there is none
+func (p *Provider) refreshToken(ctx context.Context, stale string) (string, bool) {
+	if token, ok := ctx.Value(tokenKey{}).(string); ok && token != "" {
+		return "", false
+	}
+	if _, ok, _ := p.zoneToken(ctx); ok {
+		return "", false
+	}
+
+	var token string
+	var err error
+	switch {
+	case p.usesTokenFile():
+		token, err = p.fileToken(true)
+	case p.TokenSource != nil:
+		inv, ok := p.TokenSource.(tokenInvalidator)
+		if !ok {
+			return "", false
+		}
+		inv.Invalidate(stale)
+		token, err = p.TokenSource.Token(ctx)
+	default:
+		return "", false
+	}
+	if err != nil || token == "" || token == stale {
+		return "", false
+	}
+	return token, true
+}
+
+// tokenInvalidator is implemented by the token sources caching their token,
+// to drop it once Netlify rejected it
+type tokenInvalidator interface {
+	Invalidate(token string)
+}
+
+// CredentialProvider is a TokenSource getting the access token from a secret
+// manager, such as Vault or AWS Secrets Manager, through the Fetch callback.
+// The token is cached, and fetched again once TTL has elapsed or when Netlify
+// rejects it.
+type CredentialProvider struct {
+	// Fetch retrieves the current access token from the
+	// secret manager
+	Fetch func(ctx context.Context) (string, error)
+
+	// TTL is how long a fetched token is used before fetching
+	// it again. Zero keeps it until Netlify rejects it
+	TTL time.Duration
+
+	// Clock is the source of time the age of the token is
+	// measured with. Nil uses the real time
+	Clock Clock
+
+	mu      sync.Mutex
+	token   string
+	fetched time.Time
+}
+
+// Token returns the cached token, fetching it if there is none or if it is
+// older than TTL.
+func (c *CredentialProvider) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && (c.TTL <= 0 || c.clock().Now().Sub(c.fetched) < c.TTL) {
+		return c.token, nil
+	}
+	token, err := c.Fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.token = token
+	c.fetched = c.clock().Now()
+	return token, nil
+}
+
+// clock returns the Clock of the credential provider, defaulting to the real
+// time
+func (c *CredentialProvider) clock() Clock {
+	if c.Clock != nil {
+		return c.Clock
+	}
+	return realClock{}
+}
+
+// Invalidate drops the cached token if it is token, so that the next call to
+// Token fetches it again.
+func (c *CredentialProvider) Invalidate(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+// VerifyCredentials checks that the provider can authenticate with Netlify's
+// API by listing a single DNS zone. It returns nil if the credentials work,
+// a *CredentialsError if the token is rejected or the API can't be reached,
+// or the error of the request otherwise.
+func (p *Provider) VerifyCredentials(ctx context.Context) error {
+	ctx, cancel := p.startOperation(ctx, "")
+	defer cancel()
+
+	reqURL := fmt.Sprintf("%s/dns_zones?page=1&per_page=1", p.baseURL())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return err
+	}
+
+	var raw json.RawMessage
+	err = p.doAPIRequest(req, "", true, false, true, false, &raw)
+	if err == nil {
+		return nil
+	}
+
+	var apiErr *APIError
+	var netErr *NetworkError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized:
+		return &CredentialsError{Problem: InvalidToken, Err: err}
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusForbidden:
+		return &CredentialsError{Problem: InsufficientScope, Err: err}
+	case errors.As(err, &netErr):
+		return &CredentialsError{Problem: NetworkFailure, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,45 @@
+package netlify
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCredentialProviderTTL(t *testing.T) {
+	clock := newFakeClock()
+	fetches := 0
+	c := &CredentialProvider{
+		Fetch: func(ctx context.Context) (string, error) {
+			fetches++
+			return fmt.Sprintf("nfp_token%d", fetches), nil
+		},
+		TTL:   time.Minute,
+		Clock: clock,
+	}
+
+	ctx := context.Background()
+	token := func(want string) {
+		t.Helper()
+		got, err := c.Token(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("got token %q, want %q", got, want)
+		}
+	}
+
+	token("nfp_token1")
+	clock.advance(59 * time.Second)
+	token("nfp_token1")
+
+	// the token is fetched again once TTL has elapsed
+	clock.advance(time.Second)
+	token("nfp_token2")
+
+	// and when Netlify rejected it
+	c.Invalidate("nfp_token2")
+	token("nfp_token3")
+}
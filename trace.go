@@ -0,0 +1,126 @@
+package netlify
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// RequestTiming holds the timings of a request sent to Netlify's API, as
+// passed to OnRequestTiming. The timings of the phases skipped by a request,
+// such as the TLS handshake of a reused connection, are zero
+type RequestTiming struct {
+	Method string
+	URL    string
+
+	DNSLookup    time.Duration
+	Connect      time.Duration
+	TLSHandshake time.Duration
+	// FirstByte is the time from sending the request to receiving the
+	// first byte of the response
+	FirstByte time.Duration
+	// Total is the time from starting the request to receiving the
+	// headers of the response
+	Total time.Duration
+
+	ReusedConn bool
+}
+
+// requestTrace collects the timings of a request from its httptrace hooks
+type requestTrace struct {
+	mu     sync.Mutex
+	timing RequestTiming
+
+	start, dnsStart, connectStart, tlsStart, wroteRequest time.Time
+}
+
+func (t *requestTrace) clientTrace() *httptrace.ClientTrace {
+	since := func(start time.Time) time.Duration {
+		if start.IsZero() {
+			return 0
+		}
+		return time.Since(start)
+	}
+
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.timing.ReusedConn = info.Reused
+			t.mu.Unlock()
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			t.dnsStart = time.Now()
+			t.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.timing.DNSLookup = since(t.dnsStart)
+			t.mu.Unlock()
+		},
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			t.connectStart = time.Now()
+			t.mu.Unlock()
+		},
+		ConnectDone: func(string, string, error) {
+			t.mu.Lock()
+			t.timing.Connect = since(t.connectStart)
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			t.tlsStart = time.Now()
+			t.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			t.timing.TLSHandshake = since(t.tlsStart)
+			t.mu.Unlock()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.mu.Lock()
+			t.wroteRequest = time.Now()
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.timing.FirstByte = since(t.wroteRequest)
+			t.mu.Unlock()
+		},
+	}
+}
+
+// traceRequest attaches httptrace hooks to req when TraceRequests or
+// OnRequestTiming is set. It returns the request to send, and a function to
+// call once its response headers are received to report the timings
+func (p *Provider) traceRequest(req *http.Request) (*http.Request, func()) {
+	if !p.TraceRequests && p.OnRequestTiming == nil {
+		return req, func() {}
+	}
+
+	t := &requestTrace{start: time.Now()}
+	t.timing.Method = req.Method
+	t.timing.URL = req.URL.String()
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), t.clientTrace()))
+
+	return req, func() {
+		t.mu.Lock()
+		t.timing.Total = time.Since(t.start)
+		timing := t.timing
+		t.mu.Unlock()
+
+		if p.TraceRequests {
+			p.logger().Debug("netlify API request timing",
+				"method", timing.Method, "url", timing.URL,
+				"dns_lookup", timing.DNSLookup, "connect", timing.Connect,
+				"tls_handshake", timing.TLSHandshake, "first_byte", timing.FirstByte,
+				"total", timing.Total, "reused_conn", timing.ReusedConn)
+		}
+		if p.OnRequestTiming != nil {
+			p.OnRequestTiming(timing)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package netlify
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Middleware wraps the transport used for the requests to Netlify's API, for
+// example to add headers or record timings.
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// newTransport returns the transport of a provider without HTTPClient, tuned
+// to reuse connections across bursts of requests to Netlify's API
+func newTransport() *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   16,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// httpClient returns the client used for the requests, with the proxy, TLS
+// settings and middlewares applied to its transport. It is built once and reused
+func (p *Provider) httpClient() (*http.Client, error) {
+	p.clientMu.Lock()
+	defer p.clientMu.Unlock()
+
+	if p.client != nil {
+		return p.client, nil
+	}
+
+	base := p.HTTPClient
+	if base == nil {
+		base = &http.Client{Transport: newTransport()}
+	}
+	transport := base.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	if p.ProxyURL != "" || p.TLSConfig != nil || p.CABundle != "" || p.TLSMinVersion != "" {
+		httpTransport, ok := transport.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("can't set a proxy or TLS settings on a %T transport", transport)
+		}
+		httpTransport = httpTransport.Clone()
+
+		if p.ProxyURL != "" {
+			proxyURL, err := p.proxyURL()
+			if err != nil {
+				return nil, err
+			}
+			httpTransport.Proxy = http.ProxyURL(proxyURL)
+		}
+
+		tlsConfig, err := p.tlsConfig(httpTransport.TLSClientConfig)
+		if err != nil {
+			return nil, err
+		}
+		httpTransport.TLSClientConfig = tlsConfig
+		transport = httpTransport
+	}
+
+	// the first middleware is the outermost one
+	for i := len(p.Middlewares) - 1; i >= 0; i-- {
+		transport = p.Middlewares[i](transport)
+	}
+
+	client := *base
+	client.Transport = transport
+	p.client = &client
+	return p.client, nil
+}
+
+// proxyURL returns the URL of the proxy, with the proxy credentials if set
+func (p *Provider) proxyURL() (*url.URL, error) {
+	proxyURL, err := url.Parse(p.ProxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("proxy_url: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("proxy_url: unsupported scheme %q", proxyURL.Scheme)
+	}
+	if p.ProxyUsername != "" {
+		proxyURL.User = url.UserPassword(p.ProxyUsername, p.ProxyPassword)
+	}
+	return proxyURL, nil
+}
+
+// tlsConfig returns the TLS configuration of the transport, starting from
+// TLSConfig or base and applying CABundle and TLSMinVersion
+func (p *Provider) tlsConfig(base *tls.Config) (*tls.Config, error) {
+	if p.TLSConfig != nil {
+		base = p.TLSConfig
+	}
+	var config *tls.Config
+	if base != nil {
+		config = base.Clone()
+	} else {
+		config = &tls.Config{}
+	}
+
+	if p.CABundle != "" {
+		pem, err := ioutil.ReadFile(p.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("ca_bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_bundle: no certificate found in %s", p.CABundle)
+		}
+		config.RootCAs = pool
+	}
+
+	if p.TLSMinVersion != "" {
+		version, err := parseTLSVersion(p.TLSMinVersion)
+		if err != nil {
+			return nil, err
+		}
+		config.MinVersion = version
+	}
+
+	return config, nil
+}
+
+// parseTLSVersion parses a TLS version such as "1.2"
+func parseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("tls_min_version: unknown TLS version %q", version)
+}
@@ -0,0 +1,35 @@
+package netlify
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+const modulePath = "github.com/CL0Pinette/libdns-netlify"
+
+var (
+	defaultUserAgent     string
+	defaultUserAgentOnce sync.Once
+)
+
+// userAgent returns the User-Agent header sent with every request, made of
+// the module name and version followed by UserAgent if set
+func (p *Provider) userAgent() string {
+	defaultUserAgentOnce.Do(func() {
+		version := "devel"
+		if info, ok := debug.ReadBuildInfo(); ok {
+			for _, dep := range info.Deps {
+				if dep.Path == modulePath {
+					version = dep.Version
+					break
+				}
+			}
+		}
+		defaultUserAgent = "libdns-netlify/" + version
+	})
+
+	if p.UserAgent != "" {
+		return defaultUserAgent + " " + p.UserAgent
+	}
+	return defaultUserAgent
+}
@@ -0,0 +1,167 @@
+package netlify
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/libdns/libdns"
+)
+
+// validateRecord checks the record content before it is sent to Netlify.
+// zone is the zone of the record, or empty if unknown. It returns nil if the
+// record looks valid, a *ValidationError otherwise
+func (p *Provider) validateRecord(record libdns.Record, zone string) error {
+	switch strings.ToUpper(record.Type) {
+	case "A":
+		if p.SkipIPValidation {
+			return nil
+		}
+		addr, err := netip.ParseAddr(record.Value)
+		if err != nil || !addr.Is4() {
+			return &ValidationError{Type: "A", Field: "value", Reason: fmt.Sprintf("%q for %s is not an IPv4 address", record.Value, record.Name)}
+		}
+	case "AAAA":
+		if p.SkipIPValidation {
+			return nil
+		}
+		addr, err := netip.ParseAddr(record.Value)
+		if err != nil || !addr.Is6() {
+			return &ValidationError{Type: "AAAA", Field: "value", Reason: fmt.Sprintf("%q for %s is not an IPv6 address", record.Value, record.Name)}
+		}
+	case "MX":
+		_, err := parseMX(record)
+		return err
+	case "SRV":
+		if err := validateSRVName(record.Name); err != nil {
+			return err
+		}
+		_, err := parseSRV(record)
+		return err
+	case "CAA":
+		_, err := parseCAA(record)
+		return err
+	case "ALIAS":
+		if !isApex(record.Name, zone) {
+			return &ValidationError{Type: "ALIAS", Field: "name", Reason: fmt.Sprintf("%q is not the zone apex", record.Name)}
+		}
+		if strings.TrimSpace(record.Value) == "" {
+			return &ValidationError{Type: "ALIAS", Field: "target", Reason: "missing"}
+		}
+		if err := validateName(record.Value); err != nil {
+			return &ValidationError{Type: "ALIAS", Field: "target", Reason: err.Error()}
+		}
+	}
+
+	return nil
+}
+
+// isApex reports whether name is the apex of zone. Without a zone, only
+// the relative names of the apex are recognized and absolute names are
+// assumed to be in the apex
+func isApex(name, zone string) bool {
+	if name == "" || name == "@" {
+		return true
+	}
+	if zone == "" {
+		return strings.HasSuffix(name, ".")
+	}
+	return normalizeName(name, zone) == normalizeName("", zone)
+}
+
+// netlifyDefaultTTL is the TTL Netlify gives to the records created without
+// one
+const netlifyDefaultTTL = time.Hour
+
+// applyTTL returns the TTL to send to Netlify for the record. A record
+// without TTL gets DefaultTTL if set. Otherwise it gets Netlify's default if
+// create is set, and keeps its current TTL if not. A TTL below MinTTL is
+// raised to it with a warning, as Netlify would raise it. It returns a
+// *ValidationError instead if RejectLowTTL is set
+func (p *Provider) applyTTL(record libdns.Record, create bool) (time.Duration, error) {
+	ttl := record.TTL
+	if ttl == 0 {
+		ttl = p.DefaultTTL
+	}
+	if ttl == 0 {
+		if !create {
+			return 0, nil
+		}
+		ttl = netlifyDefaultTTL
+	}
+	if ttl >= p.MinTTL {
+		return ttl, nil
+	}
+	if p.RejectLowTTL {
+		return 0, &ValidationError{Type: record.Type, Field: "TTL", Reason: fmt.Sprintf("%s is below the minimum of %s", ttl, p.MinTTL)}
+	}
+	p.logger().Warn("record TTL is below the minimum, raising it",
+		"name", record.Name, "type", record.Type, "ttl", ttl, "min_ttl", p.MinTTL)
+	return p.MinTTL, nil
+}
+
+// validateName checks that name is usable as a record name
+func validateName(name string) error {
+	name = strings.TrimSuffix(name, ".")
+	if len(name) > 253 {
+		return fmt.Errorf("name %q is longer than 253 characters", name)
+	}
+	if name == "" || name == "@" {
+		return nil
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			return fmt.Errorf("name %q has an empty label", name)
+		}
+		if len(label) > 63 {
+			return fmt.Errorf("name %q has a label longer than 63 characters", name)
+		}
+		if strings.ContainsAny(label, " \t\n") {
+			return fmt.Errorf("name %q contains whitespace", name)
+		}
+	}
+	return nil
+}
+
+// requireTypes returns an error for the first record without a type, which
+// can't be matched with the records of the zone
+func requireTypes(records []libdns.Record) error {
+	for i, rec := range records {
+		if rec.Type == "" {
+			return batchError(i, rec, &ValidationError{Type: "record", Field: "type", Reason: "missing"})
+		}
+	}
+	return nil
+}
+
+// ValidateRecords checks all the records before they are sent to Netlify. It
+// returns nil if they all look valid, or an error joining every problem found,
+// each one identifying the offending record.
+func (p *Provider) ValidateRecords(records []libdns.Record) error {
+	var errs []error
+	for i, rec := range records {
+		var recErrs []error
+		if rec.Type == "" {
+			recErrs = append(recErrs, errors.New("missing type"))
+		}
+		if err := validateName(rec.Name); err != nil {
+			recErrs = append(recErrs, err)
+		}
+		if rec.TTL < 0 {
+			recErrs = append(recErrs, fmt.Errorf("negative TTL %s", rec.TTL))
+		}
+		if _, err := p.applyTTL(rec, false); err != nil {
+			recErrs = append(recErrs, err)
+		}
+		if err := p.validateRecord(rec, ""); err != nil {
+			recErrs = append(recErrs, err)
+		}
+
+		for _, err := range recErrs {
+			errs = append(errs, batchError(i, rec, err))
+		}
+	}
+	return joinErrors(errs...)
+}
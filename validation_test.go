@@ -0,0 +1,225 @@
+package netlify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libdns/libdns"
+	"github.com/netlify/open-api/v2/go/models"
+)
+
+func TestAppendRecordsInvalidIP(t *testing.T) {
+	tests := []struct {
+		recType string
+		value   string
+	}{
+		{"A", "256.1.1.1"},
+		{"A", "192.0.2"},
+		{"A", "not-an-ip"},
+		{"A", "2001:db8::1"},
+		{"AAAA", "2001:db8::g"},
+		{"AAAA", "2001:db8:::1"},
+		{"AAAA", "192.0.2.1"},
+		{"AAAA", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.recType+" "+tt.value, func(t *testing.T) {
+			api, p := newFakeAPI(t)
+
+			_, err := p.AppendRecords(context.Background(), "example.com", []libdns.Record{
+				{Type: tt.recType, Name: "www", Value: tt.value},
+			})
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("got error %v, want a *ValidationError", err)
+			}
+			if validationErr.Type != tt.recType || validationErr.Field != "value" {
+				t.Errorf("got error on %s %s, want %s value", validationErr.Type, validationErr.Field, tt.recType)
+			}
+			if n := api.countRequests(http.MethodPost, "/dns_records"); n != 0 {
+				t.Errorf("sent %d create requests, want none", n)
+			}
+		})
+	}
+}
+
+func TestAppendRecordsValidIP(t *testing.T) {
+	api, p := newFakeAPI(t)
+
+	_, err := p.AppendRecords(context.Background(), "example.com", []libdns.Record{
+		{Type: "A", Name: "www", Value: "192.0.2.1"},
+		{Type: "AAAA", Name: "www", Value: "2001:db8::1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(api.zoneRecords("zone1")); n != 2 {
+		t.Errorf("zone has %d records, want 2", n)
+	}
+}
+
+func TestAppendRecordsSkipIPValidation(t *testing.T) {
+	api, p := newFakeAPI(t)
+	p.SkipIPValidation = true
+
+	_, err := p.AppendRecords(context.Background(), "example.com", []libdns.Record{
+		{Type: "A", Name: "www", Value: "not-an-ip"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := api.countRequests(http.MethodPost, "/dns_records"); n != 1 {
+		t.Errorf("sent %d create requests, want 1", n)
+	}
+}
+
+func TestValidateRecordsReportsEveryProblem(t *testing.T) {
+	p := &Provider{}
+	records := []libdns.Record{
+		{Type: "A", Name: "ok", Value: "192.0.2.1"},
+		{Type: "A", Name: "bad-ip", Value: "192.0.2.256"},
+		{Type: "", Name: "no-type", Value: "x"},
+		{Type: "MX", Name: "", Value: "ten mail.example.com"},
+		{Type: "TXT", Name: "bad..name", Value: "x", TTL: -time.Second},
+	}
+
+	err := p.ValidateRecords(records)
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		"record 1 (A bad-ip)",
+		"record 2 ( no-type): missing type",
+		"record 3 (MX ): MX: preference not an integer",
+		"record 4 (TXT bad..name): name",
+		"record 4 (TXT bad..name): negative TTL",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error lacks %q:\n%s", want, msg)
+		}
+	}
+	if strings.Contains(msg, "record 0 ") {
+		t.Errorf("error reports the valid record:\n%s", msg)
+	}
+	if lines := strings.Count(msg, "\n") + 1; lines != 5 {
+		t.Errorf("got %d problems, want 5:\n%s", lines, msg)
+	}
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Errorf("got error %v, want it to hold a *ValidationError", err)
+	}
+}
+
+func TestStrictValidation(t *testing.T) {
+	api, p := newFakeAPI(t)
+	p.StrictValidation = true
+
+	_, err := p.AppendRecords(context.Background(), "example.com", []libdns.Record{
+		{Type: "A", Name: "www", Value: "192.0.2.1"},
+		{Type: "A", Name: "api", Value: "bad"},
+		{Type: "AAAA", Name: "api", Value: "bad"},
+	})
+	if err == nil || strings.Count(err.Error(), "\n") != 1 {
+		t.Errorf("got error %v, want both invalid records reported", err)
+	}
+	if n := len(api.requestLog()); n != 0 {
+		t.Errorf("sent %d requests, want none", n)
+	}
+}
+
+func TestDefaultTTL(t *testing.T) {
+	api, p := newFakeAPI(t)
+	p.DefaultTTL = 5 * time.Minute
+
+	created, err := p.AppendRecords(context.Background(), "example.com", []libdns.Record{
+		{Type: "A", Name: "default", Value: "192.0.2.1"},
+		{Type: "A", Name: "explicit", Value: "192.0.2.2", TTL: 10 * time.Minute},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created[0].TTL != 5*time.Minute || created[1].TTL != 10*time.Minute {
+		t.Errorf("created records with TTLs %s and %s, want 5m0s and 10m0s", created[0].TTL, created[1].TTL)
+	}
+	stored := api.zoneRecords("zone1")
+	if stored[0].TTL != 300 || stored[1].TTL != 600 {
+		t.Errorf("sent TTLs %d and %d, want 300 and 600", stored[0].TTL, stored[1].TTL)
+	}
+}
+
+func TestMinTTLWarning(t *testing.T) {
+	api, p := newFakeAPI(t)
+	logger := &testLogger{}
+	p.Logger = logger
+	p.MinTTL = time.Minute
+
+	created, err := p.AppendRecords(context.Background(), "example.com", []libdns.Record{
+		{Type: "A", Name: "low", Value: "192.0.2.1", TTL: 30 * time.Second},
+		{Type: "A", Name: "ok", Value: "192.0.2.2", TTL: time.Minute},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	warnings := logger.logged("WARN", "below the minimum")
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "low") {
+		t.Errorf("logged %q, want a warning for the low TTL only", logger.messages)
+	}
+	if created[0].TTL != time.Minute {
+		t.Errorf("created the record with a TTL of %s, want it raised to 1m0s", created[0].TTL)
+	}
+	if stored := api.zoneRecords("zone1"); stored[0].TTL != 60 {
+		t.Errorf("sent a TTL of %d, want 60", stored[0].TTL)
+	}
+}
+
+func TestMinTTLWarningOnUpdate(t *testing.T) {
+	api, p := newFakeAPI(t)
+	api.addRecord("zone1", models.DNSRecord{Type: "A", Hostname: "low.example.com", Value: "192.0.2.1", TTL: 3600})
+	logger := &testLogger{}
+	p.Logger = logger
+	p.MinTTL = time.Minute
+
+	_, err := p.SetRecords(context.Background(), "example.com", []libdns.Record{
+		{Type: "A", Name: "low", Value: "192.0.2.2", TTL: 30 * time.Second},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if warnings := logger.logged("WARN", "below the minimum"); len(warnings) != 1 {
+		t.Errorf("logged %q, want a single warning", logger.messages)
+	}
+	if stored := api.zoneRecords("zone1"); stored[0].TTL != 60 {
+		t.Errorf("sent a TTL of %d, want 60", stored[0].TTL)
+	}
+}
+
+func TestMinTTLRejected(t *testing.T) {
+	api, p := newFakeAPI(t)
+	p.MinTTL = time.Minute
+	p.RejectLowTTL = true
+
+	_, err := p.AppendRecords(context.Background(), "example.com", []libdns.Record{
+		{Type: "A", Name: "low", Value: "192.0.2.1", TTL: 30 * time.Second},
+	})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "TTL" {
+		t.Errorf("got error %v, want a *ValidationError on the TTL", err)
+	}
+	if n := api.countRequests(http.MethodPost, ""); n != 0 {
+		t.Errorf("sent %d create requests, want none", n)
+	}
+
+	err = p.ValidateRecords([]libdns.Record{
+		{Type: "A", Name: "low", Value: "192.0.2.1", TTL: 30 * time.Second},
+	})
+	if !errors.As(err, &validationErr) || validationErr.Field != "TTL" {
+		t.Errorf("ValidateRecords got error %v, want a *ValidationError on the TTL", err)
+	}
+}